@@ -1,10 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/codecrafters-io/shell-starter-go/shell" // Import the shell package
 )
 
 func main() {
-	myShell := shell.NewShell() // Create a new shell instance
-	myShell.Run()               // Run the shell's main loop
+	invocation, err := shell.ParseInvocation(os.Args) // Parse -c, -s and script operands
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(2)
+	}
+
+	myShell := shell.NewShell()          // Create a new shell instance
+	os.Exit(myShell.Execute(invocation)) // Run the shell's main loop, a script or a command string
 }
@@ -0,0 +1,443 @@
+package arithmetic
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Variables gives an expression access to the shell's variables.
+type Variables interface {
+	// Parameter returns the value of a variable.
+	Parameter(name string) (string, bool)
+	// SetVariable assigns a value to a variable.
+	SetVariable(name string, value string) error
+}
+
+// maxDepth limits how deeply variables whose values are expressions themselves are evaluated.
+const maxDepth = 1024
+
+// binaryLevels lists the left associative binary operators from lowest to highest precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+var assignmentOperators = []string{"=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|="}
+
+// operators lists every operator, longer ones first so that tokens are matched greedily.
+var operators = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~", "&", "^", "|", "?", ":", ",", "(", ")",
+}
+
+// Evaluate evaluates an arithmetic expression with C-like integer operators, as in $((...)).
+// Variables are referred to by name, and a variable holding an expression is evaluated too.
+func Evaluate(expression string, variables Variables) (int64, error) {
+	return evaluate(expression, variables, 0)
+}
+
+func evaluate(expression string, variables Variables, depth int) (int64, error) {
+	if depth > maxDepth {
+		return 0, fmt.Errorf("expression recursion level exceeded")
+	}
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return 0, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	p := &parser{tokens: tokens, variables: variables, depth: depth}
+	value, err := p.comma(false)
+	if err == nil && p.pos < len(tokens) {
+		err = fmt.Errorf("syntax error in expression (error token is \"%s\")", strings.Join(tokens[p.pos:], " "))
+	}
+	return value, err
+}
+
+func tokenize(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case isWordChar(c):
+			start := i
+			for i < len(expression) && (isWordChar(expression[i]) || expression[i] == '#' || expression[i] == '@') {
+				i++
+			}
+			if i < len(expression) && expression[i] == '[' && isName(expression[start:i]) {
+				// An array element, name[index], where the index is itself an expression
+				depth := 0
+				for i < len(expression) {
+					if expression[i] == '[' {
+						depth++
+					} else if expression[i] == ']' {
+						depth--
+					}
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+				if depth != 0 {
+					return nil, fmt.Errorf("%s: bad array subscript", expression[start:])
+				}
+			}
+			tokens = append(tokens, expression[start:i])
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("syntax error: invalid arithmetic operator (error token is \"%s\")", expression[i:])
+			}
+			tokens = append(tokens, operator)
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isName reports whether a token is a variable name, possibly followed by a subscript.
+func isName(token string) bool {
+	token, _, _ = strings.Cut(token, "[")
+	return token != "" && !(token[0] >= '0' && token[0] <= '9') && !strings.ContainsAny(token, "#@")
+}
+
+// parser evaluates tokens by recursive descent. While skip is set, as in the unused
+// branch of && or ?:, operands are parsed without side effects or errors.
+type parser struct {
+	tokens    []string
+	pos       int
+	variables Variables
+	depth     int
+}
+
+func (p *parser) peek(offset int) string {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return ""
+}
+
+func (p *parser) expect(token string) error {
+	if p.peek(0) != token {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("syntax error: `%s' expected", token)
+		}
+		return fmt.Errorf("syntax error: `%s' expected (error token is \"%s\")", token, p.peek(0))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) comma(skip bool) (int64, error) {
+	value, err := p.assignment(skip)
+	for err == nil && p.peek(0) == "," {
+		p.pos++
+		value, err = p.assignment(skip)
+	}
+	return value, err
+}
+
+func (p *parser) assignment(skip bool) (int64, error) {
+	name, operator := p.peek(0), p.peek(1)
+	if !isName(name) || !slices.Contains(assignmentOperators, operator) {
+		return p.conditional(skip)
+	}
+	p.pos += 2
+
+	value, err := p.assignment(skip)
+	if err != nil || skip {
+		return value, err
+	}
+	if operator != "=" {
+		current, err := p.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		if value, err = apply(current, strings.TrimSuffix(operator, "="), value); err != nil {
+			return 0, err
+		}
+	}
+	return value, p.variables.SetVariable(name, strconv.FormatInt(value, 10))
+}
+
+func (p *parser) conditional(skip bool) (int64, error) {
+	condition, err := p.binary(0, skip)
+	if err != nil || p.peek(0) != "?" {
+		return condition, err
+	}
+	p.pos++
+
+	ifTrue, err := p.comma(skip || condition == 0)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.expect(":"); err != nil {
+		return 0, err
+	}
+	ifFalse, err := p.conditional(skip || condition != 0)
+	if condition != 0 {
+		return ifTrue, err
+	}
+	return ifFalse, err
+}
+
+func (p *parser) binary(level int, skip bool) (int64, error) {
+	if level == len(binaryLevels) {
+		return p.power(skip)
+	}
+
+	left, err := p.binary(level+1, skip)
+	for err == nil && slices.Contains(binaryLevels[level], p.peek(0)) {
+		operator := p.peek(0)
+		p.pos++
+
+		var right int64
+		switch operator {
+		case "&&":
+			right, err = p.binary(level+1, skip || left == 0)
+			left = boolean(left != 0 && right != 0)
+		case "||":
+			right, err = p.binary(level+1, skip || left != 0)
+			left = boolean(left != 0 || right != 0)
+		default:
+			right, err = p.binary(level+1, skip)
+			if err == nil && !skip {
+				left, err = apply(left, operator, right)
+			}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) power(skip bool) (int64, error) {
+	base, err := p.unary(skip)
+	if err != nil || p.peek(0) != "**" {
+		return base, err
+	}
+	p.pos++
+
+	exponent, err := p.power(skip) // right associative
+	if err != nil || skip {
+		return 0, err
+	}
+	return apply(base, "**", exponent)
+}
+
+func (p *parser) unary(skip bool) (int64, error) {
+	operator := p.peek(0)
+	if (operator == "++" || operator == "--") && isName(p.peek(1)) {
+		name := p.peek(1)
+		p.pos += 2
+		if skip {
+			return 0, nil
+		}
+		value, err := p.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		value += map[string]int64{"++": 1, "--": -1}[operator]
+		return value, p.variables.SetVariable(name, strconv.FormatInt(value, 10))
+	}
+
+	switch operator {
+	case "-", "+", "!", "~", "++", "--":
+		p.pos++
+		value, err := p.unary(skip)
+		switch operator {
+		case "-":
+			return -value, err
+		case "!":
+			return boolean(value == 0), err
+		case "~":
+			return ^value, err
+		case "--":
+			return value, err // two unary minuses
+		default:
+			return value, err
+		}
+	}
+	return p.postfix(skip)
+}
+
+func (p *parser) postfix(skip bool) (int64, error) {
+	token := p.peek(0)
+	switch {
+	case token == "":
+		return 0, fmt.Errorf("syntax error: operand expected")
+	case token == "(":
+		p.pos++
+		value, err := p.comma(skip)
+		if err != nil {
+			return 0, err
+		}
+		return value, p.expect(")")
+	case isName(token):
+		p.pos++
+		if skip {
+			if p.peek(0) == "++" || p.peek(0) == "--" {
+				p.pos++
+			}
+			return 0, nil
+		}
+		value, err := p.variable(token)
+		if err != nil {
+			return 0, err
+		}
+		if operator := p.peek(0); operator == "++" || operator == "--" {
+			p.pos++
+			updated := value + map[string]int64{"++": 1, "--": -1}[operator]
+			return value, p.variables.SetVariable(token, strconv.FormatInt(updated, 10))
+		}
+		return value, nil
+	case token[0] >= '0' && token[0] <= '9':
+		p.pos++
+		return parseNumber(token)
+	}
+	return 0, fmt.Errorf("syntax error: operand expected (error token is \"%s\")", token)
+}
+
+// variable returns the value of a variable, evaluating it if it holds an expression.
+func (p *parser) variable(name string) (int64, error) {
+	value, _ := p.variables.Parameter(name)
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	return evaluate(value, p.variables, p.depth+1)
+}
+
+// parseNumber parses a decimal, octal (0 prefix), hexadecimal (0x prefix) or base#digits
+// number, where the base is between 2 and 64.
+func parseNumber(token string) (int64, error) {
+	base, digits := int64(10), token
+	if b, rest, ok := strings.Cut(token, "#"); ok {
+		n, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || n < 2 || n > 64 {
+			return 0, fmt.Errorf("%s: invalid arithmetic base", token)
+		}
+		base, digits = n, rest
+	} else if strings.HasPrefix(token, "0x") || strings.HasPrefix(token, "0X") {
+		base, digits = 16, token[2:]
+	} else if len(token) > 1 && token[0] == '0' {
+		base, digits = 8, token[1:]
+	}
+	if digits == "" {
+		return 0, fmt.Errorf("%s: invalid number", token)
+	}
+
+	var value int64
+	for i := 0; i < len(digits); i++ {
+		digit := digitValue(digits[i], base)
+		if digit < 0 || digit >= base {
+			return 0, fmt.Errorf("%s: value too great for base (error token is \"%s\")", token, token)
+		}
+		value = value*base + digit
+	}
+	return value, nil
+}
+
+// digitValue returns the value of a digit: 0-9, then a-z and A-Z, then @ and _. Up to
+// base 36 letters are not case sensitive.
+func digitValue(c byte, base int64) int64 {
+	switch {
+	case c >= '0' && c <= '9':
+		return int64(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int64(c-'a') + 10
+	case c >= 'A' && c <= 'Z' && base <= 36:
+		return int64(c-'A') + 10
+	case c >= 'A' && c <= 'Z':
+		return int64(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+func apply(left int64, operator string, right int64) (int64, error) {
+	switch operator {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/", "%":
+		if right == 0 {
+			return 0, fmt.Errorf("division by 0")
+		}
+		if operator == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	case "**":
+		if right < 0 {
+			return 0, fmt.Errorf("exponent less than 0")
+		}
+		result := int64(1)
+		for ; right > 0; right >>= 1 { // exponentiation by squaring
+			if right&1 == 1 {
+				result *= left
+			}
+			left *= left
+		}
+		return result, nil
+	case "<<":
+		return left << uint64(right), nil
+	case ">>":
+		return left >> uint64(right), nil
+	case "&":
+		return left & right, nil
+	case "^":
+		return left ^ right, nil
+	case "|":
+		return left | right, nil
+	case "<":
+		return boolean(left < right), nil
+	case "<=":
+		return boolean(left <= right), nil
+	case ">":
+		return boolean(left > right), nil
+	case ">=":
+		return boolean(left >= right), nil
+	case "==":
+		return boolean(left == right), nil
+	case "!=":
+		return boolean(left != right), nil
+	}
+	return 0, fmt.Errorf("%s: unknown operator", operator)
+}
+
+func boolean(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package arithmetic
+
+import (
+	"strings"
+	"testing"
+)
+
+// testVariables is a plain map of variables.
+type testVariables map[string]string
+
+func (v testVariables) Parameter(name string) (string, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+func (v testVariables) SetVariable(name string, value string) error {
+	v[name] = value
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       int64
+	}{
+		{"", 0},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-7 / 2", -3},
+		{"-7 % 3", -1},
+		{"1 << 4 | 1", 17},
+		{"5 > 3 && 2 > 3", 0},
+		{"0 || 7", 1},
+		{"!0 + ~0", 0},
+		{"1 ? 2 : 3", 2},
+		{"0 ? 2 : 1 ? 4 : 5", 4},
+		{"1, 2, 3", 3},
+		{"010", 8},
+		{"0x1f", 31},
+		{"0X1F", 31},
+		{"2#101", 5},
+		{"8#17", 15},
+		{"16#ff", 255},
+		{"36#z", 35},
+		{"36#Z", 35},
+		{"64#a", 10},
+		{"64#A", 36},
+		{"64#@", 62},
+		{"64#_", 63},
+		{"2 ** 63", -1 << 63}, // Wraps around like bash
+		{"x", 4},
+		{"x * y", 12},
+		{"unset + 1", 1},
+		{"expr * 2", 10},
+	}
+	for _, test := range tests {
+		variables := testVariables{"x": "4", "y": "3", "expr": "x + 1"}
+		got, err := Evaluate(test.expression, variables)
+		if err != nil || got != test.want {
+			t.Errorf("Evaluate(%q) = %d, %v; want %d", test.expression, got, err, test.want)
+		}
+	}
+}
+
+func TestEvaluateAssignment(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       int64
+		x          string // value of x afterwards
+	}{
+		{"x = 5", 5, "5"},
+		{"x += 2", 6, "6"},
+		{"x <<= 2", 16, "16"},
+		{"x++", 4, "5"},
+		{"++x", 5, "5"},
+		{"x--, x", 3, "3"},
+		{"0 && (x = 9)", 0, "4"},
+		{"1 || (x = 9)", 1, "4"},
+		{"1 ? x : (x = 9)", 4, "4"},
+	}
+	for _, test := range tests {
+		variables := testVariables{"x": "4"}
+		got, err := Evaluate(test.expression, variables)
+		if err != nil || got != test.want || variables["x"] != test.x {
+			t.Errorf("Evaluate(%q) = %d, %v with x=%s; want %d with x=%s",
+				test.expression, got, err, variables["x"], test.want, test.x)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string // part of the error message
+	}{
+		{"1 / 0", "division by 0"},
+		{"1 % 0", "division by 0"},
+		{"2 ** -1", "exponent less than 0"},
+		{"08", "value too great for base"},
+		{"2#2", "value too great for base"},
+		{"1#0", "invalid arithmetic base"},
+		{"65#0", "invalid arithmetic base"},
+		{"1 +", "operand expected"},
+		{"(1 + 2", "`)' expected"},
+		{"1 2", "syntax error"},
+		{"1 ? 2", "`:' expected"},
+		{"3 = 4", "syntax error"},
+		{"loop", "recursion level exceeded"},
+	}
+	for _, test := range tests {
+		variables := testVariables{"loop": "loop"}
+		got, err := Evaluate(test.expression, variables)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Evaluate(%q) = %d, %v; want an error containing %q", test.expression, got, err, test.err)
+		}
+	}
+}
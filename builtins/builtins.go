@@ -11,17 +11,51 @@ import (
 	"github.com/codecrafters-io/shell-starter-go/types" // Import the new types package
 )
 
-// HandleEcho handles the "echo" command.
-func HandleEcho(command *types.Command) { // Parameter type changed
-	fmt.Fprintln(command.OutputStream, strings.Join(command.Args, " "))
+// HandleEcho handles the "echo" command. Leading arguments made of the letters n, e and E
+// are options: -n leaves out the trailing newline, -e expands backslash escapes and -E
+// turns that off again. escapes is the default for -e, set by the xpg_echo option.
+func HandleEcho(command *types.Command, escapes bool) int {
+	args := command.Args
+	newline := true
+	for len(args) > 0 && isEchoOption(args[0]) {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+
+	text := strings.Join(args, " ")
+	if escapes {
+		var stop bool
+		if text, stop = expandEscapes(text, echoEscapes); stop {
+			newline = false // \c ends the output right there
+		}
+	}
+	if newline {
+		text += "\n"
+	}
+	fmt.Fprint(command.OutputStream, text)
+	return 0
+}
+
+// isEchoOption reports whether arg is an option of echo rather than text to print.
+func isEchoOption(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && strings.Trim(arg[1:], "neE") == ""
 }
 
 // HandleType handles the "type" command.
-func HandleType(command *types.Command, pathFinder *fsutil.Finder, builtins []string) { // Parameter type changed
+func HandleType(command *types.Command, pathFinder *fsutil.Finder, builtins []string) int { // Parameter type changed
 	// ... rest of function using command.Args, command.OutputStream, command.ErrorStream
 	if len(command.Args) == 0 {
 		fmt.Fprintln(command.ErrorStream, "type: missing argument")
-		return
+		return 1
 	}
 
 	cmdName := command.Args[0]
@@ -29,60 +63,115 @@ func HandleType(command *types.Command, pathFinder *fsutil.Finder, builtins []st
 	for _, b := range builtins {
 		if cmdName == b {
 			fmt.Fprintf(command.OutputStream, "%s is a shell builtin\n", cmdName)
-			return
+			return 0
 		}
 	}
 
 	filePath, found := pathFinder.FindExecutablePath(cmdName)
-	if found {
-		fmt.Fprintf(command.OutputStream, "%s is %s\n", cmdName, filePath)
-	} else {
+	if !found {
 		fmt.Fprintf(command.ErrorStream, "%s: not found\n", cmdName)
+		return 1
 	}
+	fmt.Fprintf(command.OutputStream, "%s is %s\n", cmdName, filePath)
+	return 0
 }
 
 // HandlePwd handles the "pwd" command.
-func HandlePwd(command *types.Command) { // Parameter type changed
+func HandlePwd(command *types.Command) int { // Parameter type changed
 	cwd, err := os.Getwd()
 	if err != nil {
 		fmt.Fprintf(command.ErrorStream, "pwd: error getting current directory: %v\n", err)
-		return
+		return 1
 	}
 	fmt.Fprintln(command.OutputStream, cwd)
+	return 0
+}
+
+// CdContext gives cd access to the shell's variables.
+type CdContext struct {
+	Parameter       func(name string) (string, bool)
+	SetVariable     func(name string, value string) error
+	CorrectSpelling bool // Correct a slightly misspelled directory name and print the corrected path
 }
 
-// HandleCd handles the "cd" command.
-func HandleCd(command *types.Command, pathFinder *fsutil.Finder) { // Parameter type changed
-	if len(command.Args) == 0 || len(command.Args) > 1 {
-		fmt.Fprintln(command.ErrorStream, "cd: missing or too many arguments")
-		return
+// HandleCd handles the "cd" command, which changes to $HOME without an argument and to
+// $OLDPWD for "-". PWD and OLDPWD are set to the new and previous directory.
+func HandleCd(command *types.Command, pathFinder *fsutil.Finder, context CdContext) int { // Parameter type changed
+	if len(command.Args) > 1 {
+		fmt.Fprintln(command.ErrorStream, "cd: too many arguments")
+		return 1
 	}
 
-	targetPath := command.Args[0]
+	printPath := false
+	var targetPath string
+	switch {
+	case len(command.Args) == 0 || command.Args[0] == "~":
+		home, ok := context.Parameter("HOME")
+		if !ok {
+			fmt.Fprintln(command.ErrorStream, "cd: HOME not set")
+			return 1
+		}
+		targetPath = home
+	case command.Args[0] == "-":
+		oldPwd, ok := context.Parameter("OLDPWD")
+		if !ok {
+			fmt.Fprintln(command.ErrorStream, "cd: OLDPWD not set")
+			return 1
+		}
+		targetPath, printPath = oldPwd, true
+	default:
+		targetPath = command.Args[0]
+	}
+	if targetPath == "" {
+		return 0 // An empty directory name leaves the directory unchanged
+	}
 	absolutePath := pathFinder.GetAbsolutePath(targetPath)
 
-	if pathFinder.IsValidPath(absolutePath) {
-		err := os.Chdir(absolutePath)
-		if err != nil {
-			fmt.Fprintf(command.ErrorStream, "cd: %s: %v\n", targetPath, err)
+	if !pathFinder.IsValidPath(absolutePath) && context.CorrectSpelling {
+		if corrected, ok := fsutil.CorrectSpelling(targetPath); ok {
+			fmt.Fprintln(command.OutputStream, corrected)
+			absolutePath = pathFinder.GetAbsolutePath(corrected)
 		}
-	} else {
+	}
+	if !pathFinder.IsValidPath(absolutePath) {
 		fmt.Fprintf(command.ErrorStream, "cd: %s: No such file or directory\n", targetPath)
+		return 1
+	}
+	oldPwd, ok := context.Parameter("PWD")
+	if !ok {
+		oldPwd, _ = os.Getwd()
+	}
+	if err := os.Chdir(absolutePath); err != nil {
+		fmt.Fprintf(command.ErrorStream, "cd: %s: %v\n", targetPath, err)
+		return 1
+	}
+	if printPath {
+		fmt.Fprintln(command.OutputStream, absolutePath)
+	}
+
+	status := 0
+	for _, err := range []error{context.SetVariable("OLDPWD", oldPwd), context.SetVariable("PWD", absolutePath)} {
+		if err != nil {
+			fmt.Fprintf(command.ErrorStream, "cd: %v\n", err)
+			status = 1
+		}
 	}
+	return status
 }
 
-func HandleHistory(command *types.Command, history []string) {
+func HandleHistory(command *types.Command, history []string) int {
 	limit := len(history)
 	if len(command.Args) > 0 {
 		if n, err := strconv.Atoi(command.Args[0]); err == nil {
 			limit = min(limit, n)
 		} else if err != nil {
 			fmt.Fprintf(command.ErrorStream, "history: invalid number: %s\n", command.Args[0])
-			return
+			return 1
 		}
 	}
 
 	for i, cmd := range history[len(history)-limit:] {
 		fmt.Fprintf(command.OutputStream, "\t%d %s\n", len(history)-limit+i+1, cmd)
 	}
+	return 0
 }
@@ -0,0 +1,172 @@
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/codecrafters-io/shell-starter-go/pattern"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// ConditionalContext connects the evaluation of a [[ ]] expression to the shell.
+type ConditionalContext struct {
+	// Expand expands an operand without field splitting or globbing. If quote is not
+	// nil it escapes the quoted parts, so they match literally in a pattern.
+	Expand func(word string, quote func(string) string) (string, error)
+	// SetMatch records the text matched by =~ and its groups in BASH_REMATCH.
+	SetMatch func(groups []string)
+}
+
+// HandleConditional handles the "[[ ]]" command, whose arguments are the raw tokens of
+// the expression. Returns 0 if it is true, 1 if it is false and 2 if it is invalid.
+func HandleConditional(command *types.Command, context ConditionalContext) int {
+	parser := &conditionalParser{tokens: command.Args, context: context}
+	result, err := parser.parseOr(true)
+	if err == nil && parser.pos < len(parser.tokens) {
+		err = fmt.Errorf("syntax error near `%s'", parser.tokens[parser.pos])
+	}
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "[[: %v\n", err)
+		return 2
+	}
+	if result {
+		return 0
+	}
+	return 1
+}
+
+// conditionalParser evaluates a [[ ]] expression by recursive descent. Operands are
+// only expanded when evaluate is set, which makes && and || short-circuit.
+type conditionalParser struct {
+	tokens  []string
+	pos     int
+	context ConditionalContext
+}
+
+func (p *conditionalParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *conditionalParser) parseOr(evaluate bool) (bool, error) {
+	result, err := p.parseAnd(evaluate)
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var next bool
+		next, err = p.parseAnd(evaluate && !result)
+		result = result || next
+	}
+	return result, err
+}
+
+func (p *conditionalParser) parseAnd(evaluate bool) (bool, error) {
+	result, err := p.parseNot(evaluate)
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var next bool
+		next, err = p.parseNot(evaluate && result)
+		result = result && next
+	}
+	return result, err
+}
+
+func (p *conditionalParser) parseNot(evaluate bool) (bool, error) {
+	if p.peek() == "!" {
+		p.pos++
+		result, err := p.parseNot(evaluate)
+		return !result, err
+	}
+	return p.parsePrimary(evaluate)
+}
+
+func (p *conditionalParser) parsePrimary(evaluate bool) (bool, error) {
+	token := p.peek()
+	if token == "" || token == "&&" || token == "||" || token == ")" {
+		return false, fmt.Errorf("syntax error in conditional expression")
+	}
+
+	if token == "(" {
+		p.pos++
+		result, err := p.parseOr(evaluate)
+		if err != nil {
+			return false, err
+		}
+		if p.peek() != ")" {
+			return false, fmt.Errorf("expected `)'")
+		}
+		p.pos++
+		return result, nil
+	}
+
+	if p.pos+2 < len(p.tokens) && (p.tokens[p.pos+1] == "=~" || slices.Contains(binaryTestOperators, p.tokens[p.pos+1])) {
+		left, operator, right := token, p.tokens[p.pos+1], p.tokens[p.pos+2]
+		p.pos += 3
+		if !evaluate {
+			return false, nil
+		}
+		return p.binary(left, operator, right)
+	}
+
+	if slices.Contains(unaryTestOperators, token) && p.pos+1 < len(p.tokens) && !isConditionalOperator(p.tokens[p.pos+1]) {
+		p.pos += 2
+		if !evaluate {
+			return false, nil
+		}
+		operand, err := p.context.Expand(p.tokens[p.pos-1], nil)
+		if err != nil {
+			return false, err
+		}
+		return unaryTest(token, operand)
+	}
+
+	p.pos++
+	if !evaluate {
+		return false, nil
+	}
+	value, err := p.context.Expand(token, nil)
+	return value != "", err
+}
+
+func (p *conditionalParser) binary(leftWord string, operator string, rightWord string) (bool, error) {
+	left, err := p.context.Expand(leftWord, nil)
+	if err != nil {
+		return false, err
+	}
+
+	switch operator {
+	case "==", "=", "!=":
+		// The right-hand side is a pattern in which only quoted text is literal, and
+		// extended patterns like @(a|b) are always recognised
+		right, err := p.context.Expand(rightWord, pattern.Escape)
+		if err != nil {
+			return false, err
+		}
+		matched := pattern.Match(right, left, pattern.Options{ExtGlob: true})
+		return matched == (operator != "!="), nil
+	case "=~":
+		right, err := p.context.Expand(rightWord, regexp.QuoteMeta)
+		if err != nil {
+			return false, err
+		}
+		re, err := regexp.Compile(right)
+		if err != nil {
+			return false, fmt.Errorf("%s: invalid regular expression", right)
+		}
+		groups := re.FindStringSubmatch(left)
+		p.context.SetMatch(groups)
+		return groups != nil, nil
+	}
+
+	right, err := p.context.Expand(rightWord, nil)
+	if err != nil {
+		return false, err
+	}
+	return binaryTest(left, operator, right)
+}
+
+func isConditionalOperator(token string) bool {
+	return token == "&&" || token == "||" || token == ")"
+}
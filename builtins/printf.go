@@ -0,0 +1,441 @@
+package builtin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// PrintfContext connects the printf builtin to the shell.
+type PrintfContext struct {
+	SetVariable func(name string, value string) error // Assigns the output for -v
+	StartTime   time.Time                             // Time the shell started, for %(fmt)T with -2
+}
+
+// HandlePrintf handles the "printf" command:
+//
+//	printf [-v var] format [argument...]
+//
+// The format is printed with its backslash escapes expanded and its conversion
+// specifications replaced by the arguments. While arguments are left over, the format
+// is used again. Missing arguments count as empty strings or zero.
+func HandlePrintf(command *types.Command, context PrintfContext) int {
+	args := command.Args
+	variable := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		if arg == "-v" && len(args) > 0 {
+			variable, args = args[0], args[1:]
+		} else if strings.HasPrefix(arg, "-v") && len(arg) > 2 {
+			variable = arg[2:]
+		} else {
+			fmt.Fprintf(command.ErrorStream, "printf: %s: invalid option\n", arg)
+			fmt.Fprintln(command.ErrorStream, "printf: usage: printf [-v var] format [arguments]")
+			return 2
+		}
+	}
+	if variable != "" && !parser.IsValidName(variable) {
+		fmt.Fprintf(command.ErrorStream, "printf: `%s': not a valid identifier\n", variable)
+		return 2
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(command.ErrorStream, "printf: usage: printf [-v var] format [arguments]")
+		return 2
+	}
+
+	var assigned strings.Builder
+	var output io.Writer = command.OutputStream
+	if variable != "" {
+		output = &assigned
+	}
+	p := &printer{format: args[0], args: args[1:], output: bufio.NewWriter(output), context: context, errors: command.ErrorStream}
+	for {
+		consumed := p.arg
+		if !p.printFormat() {
+			break // stopped by \c
+		}
+		if p.arg >= len(p.args) || p.arg == consumed {
+			break
+		}
+	}
+	p.output.Flush()
+
+	if variable != "" {
+		if err := context.SetVariable(variable, assigned.String()); err != nil {
+			fmt.Fprintf(command.ErrorStream, "printf: %v\n", err)
+			return 1
+		}
+	}
+	return p.status
+}
+
+// printer formats the arguments of a printf command.
+type printer struct {
+	format  string
+	args    []string
+	arg     int // index of the next argument
+	output  *bufio.Writer
+	status  int
+	context PrintfContext
+	errors  io.Writer
+}
+
+func (p *printer) nextArg() string {
+	if p.arg >= len(p.args) {
+		return ""
+	}
+	p.arg++
+	return p.args[p.arg-1]
+}
+
+// printFormat prints the format once. Returns false if output has to stop because of \c.
+func (p *printer) printFormat() bool {
+	format := p.format
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '\\':
+			text, length, _ := expandEscape(format[i:], formatEscapes)
+			p.output.WriteString(text)
+			i += length - 1
+		case c == '%' && i+1 < len(format) && format[i+1] == '%':
+			p.output.WriteByte('%')
+			i++
+		case c == '%':
+			end, ok := p.printConversion(format, i)
+			if !ok {
+				return false
+			}
+			i = end
+		default:
+			p.output.WriteByte(c)
+		}
+	}
+	return true
+}
+
+// printConversion prints the conversion specification starting at format[start] and
+// returns the index of its last byte. Returns false if output has to stop.
+func (p *printer) printConversion(format string, start int) (int, bool) {
+	i := start + 1
+	flags := ""
+	for i < len(format) && strings.IndexByte("-+ #0'", format[i]) >= 0 {
+		if format[i] != '\'' { // digit grouping is not supported
+			flags += string(format[i])
+		}
+		i++
+	}
+
+	width, i := p.readNumber(format, i)
+	precision := ""
+	if i < len(format) && format[i] == '.' {
+		precision, i = p.readNumber(format, i+1)
+		switch {
+		case strings.HasPrefix(precision, "-"):
+			precision = "" // a negative precision from '*' is ignored
+		case precision == "":
+			precision = ".0"
+		default:
+			precision = "." + precision
+		}
+	}
+
+	// %(fmt)T formats a time given in seconds since the epoch
+	if i < len(format) && format[i] == '(' {
+		closing := strings.Index(format[i:], ")T")
+		if closing == -1 {
+			p.fail("`(': invalid time format specification")
+			return len(format), false
+		}
+		timeFormat := format[i+1 : i+closing]
+		fmt.Fprintf(p.output, "%"+flags+width+precision+"s", p.formatTime(timeFormat, p.nextArg()))
+		return i + closing + 1, true
+	}
+
+	if i >= len(format) {
+		p.fail(fmt.Sprintf("`%s': missing format character", format[start:]))
+		return len(format), false
+	}
+	spec := "%" + flags + width + precision
+	conversion := format[i]
+	switch conversion {
+	case 'd', 'i':
+		fmt.Fprintf(p.output, spec+"d", p.integerArg())
+	case 'o', 'u', 'x', 'X':
+		verb := map[byte]string{'o': "o", 'u': "d", 'x': "x", 'X': "X"}[conversion]
+		fmt.Fprintf(p.output, spec+verb, uint64(p.integerArg()))
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if precision == "" && (conversion == 'g' || conversion == 'G') {
+			spec += ".6" // like C, unlike Go, %g has a default precision
+		}
+		fmt.Fprintf(p.output, spec+string(conversion), p.floatArg())
+	case 'c':
+		arg := p.nextArg()
+		if arg != "" {
+			_, size := utf8.DecodeRuneInString(arg)
+			arg = arg[:size]
+		}
+		fmt.Fprintf(p.output, "%"+flags+width+"s", arg)
+	case 's':
+		fmt.Fprintf(p.output, spec+"s", p.nextArg())
+	case 'b':
+		text, stop := expandEscapes(p.nextArg(), argumentEscapes)
+		fmt.Fprintf(p.output, spec+"s", text)
+		if stop {
+			return i, false
+		}
+	case 'q':
+		fmt.Fprintf(p.output, "%"+flags+width+"s", quoteWord(p.nextArg()))
+	default:
+		p.fail(fmt.Sprintf("`%c': invalid format character", conversion))
+		return i, false
+	}
+	return i, true
+}
+
+// readNumber reads a width or precision: digits, or '*' to take it from the next argument.
+func (p *printer) readNumber(format string, i int) (string, int) {
+	if i < len(format) && format[i] == '*' {
+		return strconv.FormatInt(p.integerArg(), 10), i + 1
+	}
+	start := i
+	for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+		i++
+	}
+	return format[start:i], i
+}
+
+func (p *printer) fail(message string) {
+	p.output.Flush() // keep the output and the error in order
+	fmt.Fprintf(p.errors, "printf: %s\n", message)
+	p.status = 1
+}
+
+// integerArg converts the next argument to an integer.
+func (p *printer) integerArg() int64 {
+	return p.integer(p.nextArg())
+}
+
+// integer converts an argument to an integer. Besides decimal, octal and hexadecimal
+// numbers, a leading quote gives the code of the character after it.
+func (p *printer) integer(arg string) int64 {
+	if code, ok := characterCode(arg); ok {
+		return code
+	}
+	trimmed := strings.TrimSpace(arg)
+	if trimmed == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(trimmed, 0, 64)
+	if err != nil {
+		if u, uerr := strconv.ParseUint(trimmed, 0, 64); uerr == nil {
+			return int64(u) // large unsigned values wrap around like in C
+		}
+		p.fail(fmt.Sprintf("%s: invalid number", arg))
+		return leadingInteger(trimmed)
+	}
+	return n
+}
+
+func (p *printer) floatArg() float64 {
+	arg := p.nextArg()
+	if code, ok := characterCode(arg); ok {
+		return float64(code)
+	}
+	trimmed := strings.TrimSpace(arg)
+	if trimmed == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil && !math.IsInf(f, 0) {
+		p.fail(fmt.Sprintf("%s: invalid number", arg))
+		return float64(leadingInteger(trimmed))
+	}
+	return f
+}
+
+// characterCode returns the code of the character after a leading ' or ".
+func characterCode(arg string) (int64, bool) {
+	if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(arg[1:])
+	return int64(r), true
+}
+
+// leadingInteger returns the value of the decimal digits at the start of s, like strtol.
+func leadingInteger(s string) int64 {
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.ParseInt(s[:end], 10, 64)
+	return n
+}
+
+// formatTime formats the time given in seconds since the epoch for %(fmt)T. -1 or an
+// empty argument is the current time and -2 the time the shell started.
+func (p *printer) formatTime(format string, arg string) string {
+	t := time.Now()
+	if arg != "" {
+		switch seconds := p.integer(arg); seconds {
+		case -1:
+		case -2:
+			t = p.context.StartTime
+		default:
+			t = time.Unix(seconds, 0)
+		}
+	}
+	if format == "" {
+		format = "%X"
+	}
+	return strftime(format, t)
+}
+
+// escapeStyle selects the dialect of backslash escapes.
+type escapeStyle int
+
+const (
+	formatEscapes   escapeStyle = iota // printf formats: octal is \nnn
+	argumentEscapes                    // %b arguments: octal is \nnn or \0nnn, \c stops the output
+	echoEscapes                        // echo -e: octal is \0nnn, \c stops the output
+)
+
+// expandEscapes expands the backslash escapes in s. Returns true if the output has to
+// stop at a \c.
+func expandEscapes(s string, style escapeStyle) (string, bool) {
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			result.WriteByte(s[i])
+			continue
+		}
+		text, length, stop := expandEscape(s[i:], style)
+		if stop {
+			return result.String(), true
+		}
+		result.WriteString(text)
+		i += length - 1
+	}
+	return result.String(), false
+}
+
+// expandEscape expands the backslash escape at the start of s. Returns its expansion,
+// its length in s, and whether it is \c, which stops the output.
+func expandEscape(s string, style escapeStyle) (string, int, bool) {
+	if len(s) < 2 {
+		return s, len(s), false
+	}
+	if simple, ok := simpleEscapes[s[1]]; ok {
+		return simple, 2, false
+	}
+	switch c := s[1]; {
+	case c == 'c' && style != formatEscapes:
+		return "", 2, true
+	case c >= '0' && c <= '7':
+		start := 1
+		if style != formatEscapes && c == '0' {
+			start = 2 // \0nnn
+		} else if style == echoEscapes {
+			return s[:2], 2, false
+		}
+		value, digits := parseDigits(s[start:], 8, 3)
+		return string([]byte{byte(value)}), start + digits, false
+	case c == 'x':
+		value, digits := parseDigits(s[2:], 16, 2)
+		if digits == 0 {
+			return s[:2], 2, false
+		}
+		return string([]byte{byte(value)}), 2 + digits, false
+	case c == 'u' || c == 'U':
+		maxDigits := 4
+		if c == 'U' {
+			maxDigits = 8
+		}
+		value, digits := parseDigits(s[2:], 16, maxDigits)
+		if digits == 0 {
+			return s[:2], 2, false
+		}
+		return string(rune(value)), 2 + digits, false
+	}
+	return s[:2], 2, false
+}
+
+var simpleEscapes = map[byte]string{
+	'\\': "\\", 'a': "\a", 'b': "\b", 'e': "\x1b", 'E': "\x1b", 'f': "\f",
+	'n': "\n", 'r': "\r", 't': "\t", 'v': "\v", '"': "\"", '\'': "'", '?': "?",
+}
+
+// parseDigits parses up to maxDigits digits in the given base at the start of s. Returns
+// the value and the number of digits read.
+func parseDigits(s string, base int, maxDigits int) (int64, int) {
+	digits := 0
+	for digits < len(s) && digits < maxDigits && isDigit(s[digits], base) {
+		digits++
+	}
+	value, _ := strconv.ParseInt(s[:digits], base, 64)
+	return value, digits
+}
+
+func isDigit(c byte, base int) bool {
+	if base == 16 {
+		return strings.IndexByte("0123456789abcdefABCDEF", c) >= 0
+	}
+	return c >= '0' && c < '0'+byte(base)
+}
+
+// quoteWord quotes s for %q so the shell reads it back as one word. Special characters
+// are escaped with backslashes, and control characters use the $'...' form.
+func quoteWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.ContainsFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		var result strings.Builder
+		result.WriteString("$'")
+		for i := 0; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' || c == '\'':
+				result.WriteString("\\" + string(c))
+			case c == '\n':
+				result.WriteString("\\n")
+			case c == '\t':
+				result.WriteString("\\t")
+			case c == '\r':
+				result.WriteString("\\r")
+			case c == 0x1b:
+				result.WriteString("\\E")
+			case c < ' ' || c == 0x7f:
+				result.WriteString(fmt.Sprintf("\\%03o", c))
+			default:
+				result.WriteByte(c)
+			}
+		}
+		result.WriteString("'")
+		return result.String()
+	}
+
+	var result strings.Builder
+	for i, r := range s {
+		if strings.ContainsRune(" \t\\'\"`$&|;()<>*?[]{}!^", r) || (i == 0 && (r == '~' || r == '#')) {
+			result.WriteByte('\\')
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
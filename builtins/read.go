@@ -0,0 +1,295 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// ReadContext connects the read builtin to the shell's variables.
+type ReadContext struct {
+	IFS         string // Characters separating the fields of a line
+	SetVariable func(name string, value string) error
+	SetArray    func(name string, values []string) error
+	Descriptor  func(fd int) (*os.File, bool) // New file for a descriptor exec opened, closed by the caller
+}
+
+// readOptions holds the options of a read command.
+type readOptions struct {
+	raw       bool    // -r: backslashes are not escape characters
+	silent    bool    // -s: input from a terminal is not echoed
+	array     string  // -a: name of the array the fields are assigned to
+	delimiter byte    // -d: character that ends the input instead of newline
+	count     int     // -n: maximum number of characters to read, -1 for no limit
+	prompt    string  // -p: prompt printed when reading from a terminal
+	timeout   float64 // -t: seconds to wait for a complete line, -1 for no limit
+	fd        int     // -u: file descriptor to read from instead of the command's input
+	names     []string
+}
+
+// HandleRead handles the "read" command:
+//
+//	read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [-u fd] [name...]
+//
+// It reads a line and splits it at the characters of IFS into the named variables, the
+// last of which gets the rest of the line, or into an array with -a. Without names the
+// whole line is assigned to REPLY. Input is read one byte at a time, so that nothing
+// after the line is taken away from the commands that run next. Returns 0 if a line was
+// read, 1 at the end of input and more than 128 if the timeout expired.
+func HandleRead(command *types.Command, context ReadContext) int {
+	options, err := parseReadOptions(command.Args)
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "read: %v\n", err)
+		fmt.Fprintln(command.ErrorStream, "read: usage: read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [-u fd] [name ...]")
+		return 2
+	}
+	for _, name := range append(options.names, options.array) {
+		if name != "" && !parser.IsValidName(name) {
+			fmt.Fprintf(command.ErrorStream, "read: `%s': not a valid identifier\n", name)
+			return 1
+		}
+	}
+
+	input := command.InputStream
+	if options.fd != 0 {
+		file, duplicated, ok := descriptorFile(command, options.fd, context)
+		if !ok {
+			fmt.Fprintf(command.ErrorStream, "read: %d: invalid file descriptor: Bad file descriptor\n", options.fd)
+			return 1
+		}
+		if duplicated {
+			defer file.Close()
+		}
+		input = file
+	}
+	fd := int(input.Fd())
+
+	if options.timeout == 0 {
+		// Only tells whether there is input, without reading it
+		if ready, err := waitReadable(fd, time.Now()); err == nil && ready {
+			return 0
+		}
+		return 1
+	}
+
+	terminal := isTerminal(fd)
+	if terminal && options.prompt != "" {
+		fmt.Fprint(command.ErrorStream, options.prompt)
+	}
+	if terminal && (options.silent || options.count >= 0 || options.delimiter != '\n') {
+		lineEditing := options.count < 0 && options.delimiter == '\n'
+		if restore, err := setTerminalMode(fd, lineEditing, !options.silent); err == nil {
+			defer restore()
+		}
+	}
+
+	line, escaped, status := readLine(input, options)
+	if options.array != "" {
+		context.SetArray(options.array, splitFields(line, escaped, context.IFS, -1))
+		return status
+	}
+	if len(options.names) == 0 {
+		context.SetVariable("REPLY", string(line))
+		return status
+	}
+
+	fields := splitFields(line, escaped, context.IFS, len(options.names))
+	for i, name := range options.names {
+		value := ""
+		if i < len(fields) {
+			value = fields[i]
+		}
+		if err := context.SetVariable(name, value); err != nil {
+			fmt.Fprintf(command.ErrorStream, "read: %v\n", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// descriptorFile returns the file "read -u fd" reads from: the file the command redirects
+// fd to, one of its standard streams, or a duplicate of a descriptor the shell keeps open
+// after exec opened it, which is reported so that the caller closes it.
+func descriptorFile(command *types.Command, fd int, context ReadContext) (*os.File, bool, bool) {
+	if file, ok := command.Descriptors[fd]; ok {
+		return file, false, file != nil // nil if the redirection closed it
+	}
+	switch fd {
+	case 0:
+		return command.InputStream, false, true
+	case 1:
+		return command.OutputStream, false, true
+	case 2:
+		return command.ErrorStream, false, true
+	}
+	file, ok := context.Descriptor(fd)
+	return file, true, ok
+}
+
+func parseReadOptions(args []string) (*readOptions, error) {
+	options := &readOptions{delimiter: '\n', count: -1, timeout: -1}
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+
+		for i := 1; i < len(arg); i++ {
+			flag := arg[i]
+			switch flag {
+			case 'r':
+				options.raw = true
+				continue
+			case 's':
+				options.silent = true
+				continue
+			case 'a', 'd', 'n', 'p', 't', 'u':
+			default:
+				return nil, fmt.Errorf("-%c: invalid option", flag)
+			}
+
+			// The value follows the option letter or is the next argument
+			value := arg[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("-%c: option requires an argument", flag)
+				}
+				value, args = args[0], args[1:]
+			}
+			i = len(arg)
+
+			switch flag {
+			case 'a':
+				options.array = value
+			case 'd':
+				options.delimiter = 0 // an empty delimiter ends the input at a NUL byte
+				if value != "" {
+					options.delimiter = value[0]
+				}
+			case 'n':
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%s: invalid number", value)
+				}
+				options.count = n
+			case 'p':
+				options.prompt = value
+			case 't':
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err != nil || seconds < 0 {
+					return nil, fmt.Errorf("%s: invalid timeout specification", value)
+				}
+				options.timeout = seconds
+			case 'u':
+				fd, err := strconv.Atoi(value)
+				if err != nil || fd < 0 {
+					return nil, fmt.Errorf("%s: invalid file descriptor specification", value)
+				}
+				options.fd = fd
+			}
+		}
+	}
+	options.names = args
+	return options, nil
+}
+
+// readLine reads up to the delimiter, which is dropped, one byte at a time. Unless raw, a
+// backslash escapes the next byte, which is marked in escaped, and a backslash-newline
+// pair is removed. Returns the status of read: 1 if the input ended before the
+// delimiter or an error occurred, 142 if the timeout expired.
+func readLine(input *os.File, options *readOptions) ([]byte, []bool, int) {
+	var (
+		line         []byte
+		escaped      []bool
+		chars        int
+		continuation int  // UTF-8 continuation bytes still belonging to the last character
+		escape       bool // the previous byte was an escaping backslash
+		deadline     = time.Now().Add(time.Duration(options.timeout * float64(time.Second)))
+		buffer       = make([]byte, 1)
+	)
+	for options.count < 0 || chars < options.count || continuation > 0 {
+		if options.timeout > 0 {
+			if ready, err := waitReadable(int(input.Fd()), deadline); err != nil || !ready {
+				return line, escaped, 128 + int(syscall.SIGALRM)
+			}
+		}
+		if n, err := input.Read(buffer); n == 0 || err != nil {
+			return line, escaped, 1
+		}
+
+		b := buffer[0]
+		wasEscaped := escape
+		escape = false
+		switch {
+		case wasEscaped && b == '\n':
+			continue // line continuation
+		case wasEscaped:
+		case b == '\\' && !options.raw:
+			escape = true
+			continue
+		case b == options.delimiter:
+			return line, escaped, 0
+		}
+
+		line = append(line, b)
+		escaped = append(escaped, wasEscaped)
+		switch {
+		case continuation > 0:
+			continuation--
+		case b >= 0xF0:
+			continuation, chars = 3, chars+1
+		case b >= 0xE0:
+			continuation, chars = 2, chars+1
+		case b >= 0xC0:
+			continuation, chars = 1, chars+1
+		default:
+			chars++
+		}
+	}
+	return line, escaped, 0
+}
+
+// splitFields splits line into fields at the unescaped characters of ifs. Runs of IFS
+// whitespace are one delimiter and leading and trailing IFS whitespace is ignored. With
+// a positive limit, the last field is the rest of the line.
+func splitFields(line []byte, escaped []bool, ifs string, limit int) []string {
+	isDelimiter := func(i int) bool { return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0 }
+	isWhitespace := func(i int) bool { return isDelimiter(i) && strings.IndexByte(" \t\n", line[i]) >= 0 }
+
+	var fields []string
+	i := 0
+	for i < len(line) && isWhitespace(i) {
+		i++
+	}
+	for i < len(line) {
+		if len(fields) == limit-1 {
+			end := len(line)
+			for end > i && isWhitespace(end-1) {
+				end--
+			}
+			return append(fields, string(line[i:end]))
+		}
+
+		start := i
+		for i < len(line) && !isDelimiter(i) {
+			i++
+		}
+		fields = append(fields, string(line[start:i]))
+
+		for i < len(line) && isWhitespace(i) {
+			i++
+		}
+		if i < len(line) && isDelimiter(i) && !isWhitespace(i) {
+			for i++; i < len(line) && isWhitespace(i); i++ {
+			}
+		}
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package builtin
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// strftime formats t like the C function of the same name.
+func strftime(format string, t time.Time) string {
+	var result strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			result.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case 'a':
+			result.WriteString(t.Format("Mon"))
+		case 'A':
+			result.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			result.WriteString(t.Format("Jan"))
+		case 'B':
+			result.WriteString(t.Format("January"))
+		case 'c':
+			result.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case 'C':
+			fmt.Fprintf(&result, "%02d", t.Year()/100)
+		case 'd':
+			fmt.Fprintf(&result, "%02d", t.Day())
+		case 'D', 'x':
+			result.WriteString(t.Format("01/02/06"))
+		case 'e':
+			fmt.Fprintf(&result, "%2d", t.Day())
+		case 'F':
+			result.WriteString(t.Format("2006-01-02"))
+		case 'H':
+			fmt.Fprintf(&result, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(&result, "%02d", (t.Hour()+11)%12+1)
+		case 'j':
+			fmt.Fprintf(&result, "%03d", t.YearDay())
+		case 'k':
+			fmt.Fprintf(&result, "%2d", t.Hour())
+		case 'l':
+			fmt.Fprintf(&result, "%2d", (t.Hour()+11)%12+1)
+		case 'm':
+			fmt.Fprintf(&result, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(&result, "%02d", t.Minute())
+		case 'n':
+			result.WriteByte('\n')
+		case 'p':
+			result.WriteString(t.Format("PM"))
+		case 'r':
+			result.WriteString(t.Format("03:04:05 PM"))
+		case 'R':
+			result.WriteString(t.Format("15:04"))
+		case 's':
+			fmt.Fprintf(&result, "%d", t.Unix())
+		case 'S':
+			fmt.Fprintf(&result, "%02d", t.Second())
+		case 't':
+			result.WriteByte('\t')
+		case 'T', 'X':
+			result.WriteString(t.Format("15:04:05"))
+		case 'u':
+			fmt.Fprintf(&result, "%d", (int(t.Weekday())+6)%7+1)
+		case 'w':
+			fmt.Fprintf(&result, "%d", int(t.Weekday()))
+		case 'y':
+			fmt.Fprintf(&result, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(&result, "%d", t.Year())
+		case 'z':
+			result.WriteString(t.Format("-0700"))
+		case 'Z':
+			result.WriteString(t.Format("MST"))
+		case '%':
+			result.WriteByte('%')
+		default:
+			result.WriteByte('%')
+			result.WriteByte(c)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,61 @@
+//go:build linux
+
+package builtin
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// isTerminal reports whether fd refers to a terminal.
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctlTermios(fd, syscall.TCGETS, &termios) == nil
+}
+
+// setTerminalMode turns off the line editing or the echo of the terminal fd, and returns
+// a function that restores its previous mode.
+func setTerminalMode(fd int, lineEditing bool, echo bool) (func(), error) {
+	var previous syscall.Termios
+	if err := ioctlTermios(fd, syscall.TCGETS, &previous); err != nil {
+		return nil, err
+	}
+
+	mode := previous
+	if !lineEditing {
+		mode.Lflag &^= syscall.ICANON
+		mode.Cc[syscall.VMIN], mode.Cc[syscall.VTIME] = 1, 0 // every byte can be read at once
+	}
+	if !echo {
+		mode.Lflag &^= syscall.ECHO
+	}
+	if err := ioctlTermios(fd, syscall.TCSETS, &mode); err != nil {
+		return nil, err
+	}
+	return func() { ioctlTermios(fd, syscall.TCSETS, &previous) }, nil
+}
+
+func ioctlTermios(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// waitReadable waits until fd has input to read. Returns false if there is none by the deadline.
+func waitReadable(fd int, deadline time.Time) (bool, error) {
+	for {
+		var set syscall.FdSet
+		bits := int(unsafe.Sizeof(set.Bits[0])) * 8
+		set.Bits[fd/bits] |= 1 << (uint(fd) % uint(bits))
+
+		timeout := syscall.NsecToTimeval(max(time.Until(deadline), 0).Nanoseconds())
+		n, err := syscall.Select(fd+1, &set, nil, nil, &timeout)
+		if err == syscall.EINTR {
+			continue // interrupted by a signal, wait for the rest of the time
+		}
+		return n > 0, err
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package builtin
+
+import "time"
+
+// isTerminal reports whether fd refers to a terminal. Terminals are only recognised on Linux.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// setTerminalMode leaves the terminal as it is outside Linux.
+func setTerminalMode(fd int, lineEditing bool, echo bool) (func(), error) {
+	return func() {}, nil
+}
+
+// waitReadable does not wait outside Linux, so read timeouts have no effect there.
+func waitReadable(fd int, deadline time.Time) (bool, error) {
+	return true, nil
+}
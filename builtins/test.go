@@ -0,0 +1,270 @@
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+var (
+	unaryTestOperators  = []string{"-e", "-f", "-d", "-r", "-w", "-x", "-s", "-L", "-h", "-p", "-S", "-b", "-c", "-g", "-u", "-k", "-z", "-n"}
+	binaryTestOperators = []string{"=", "==", "!=", "<", ">", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-nt", "-ot", "-ef"}
+)
+
+// HandleTest handles the "test" and "[" commands. Returns 0 if the expression is true,
+// 1 if it is false and 2 if it is invalid.
+func HandleTest(command *types.Command) int {
+	args := command.Args
+	if command.Name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintln(command.ErrorStream, "[: missing `]'")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+
+	result, err := evaluateTest(args)
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "%s: %v\n", command.Name, err)
+		return 2
+	}
+	if result {
+		return 0
+	}
+	return 1
+}
+
+// evaluateTest applies the POSIX rules that decide the meaning of up to four
+// arguments by their count, and parses longer expressions with precedence.
+func evaluateTest(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if slices.Contains(unaryTestOperators, args[0]) {
+			return unaryTest(args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if slices.Contains(binaryTestOperators, args[1]) {
+			return binaryTest(args[0], args[1], args[2])
+		}
+		if args[0] == "!" {
+			result, err := evaluateTest(args[1:])
+			return !result && err == nil, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return args[1] != "", nil
+		}
+	case 4:
+		if args[0] == "!" {
+			result, err := evaluateTest(args[1:])
+			return !result && err == nil, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return evaluateTest(args[1:3])
+		}
+	}
+
+	parser := &testParser{args: args}
+	result, err := parser.parseOr()
+	if err == nil && parser.pos < len(args) {
+		err = fmt.Errorf("%s: too many arguments", args[parser.pos])
+	}
+	return result, err
+}
+
+// testParser evaluates a test expression by recursive descent. From lowest to highest
+// precedence the operators are -o, -a and !, with parentheses for grouping.
+type testParser struct {
+	args []string
+	pos  int
+}
+
+func (p *testParser) peek() string {
+	if p.pos < len(p.args) {
+		return p.args[p.pos]
+	}
+	return ""
+}
+
+func (p *testParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	for err == nil && p.peek() == "-o" {
+		p.pos++
+		var next bool
+		next, err = p.parseAnd()
+		result = result || next
+	}
+	return result, err
+}
+
+func (p *testParser) parseAnd() (bool, error) {
+	result, err := p.parseNot()
+	for err == nil && p.peek() == "-a" {
+		p.pos++
+		var next bool
+		next, err = p.parseNot()
+		result = result && next
+	}
+	return result, err
+}
+
+func (p *testParser) parseNot() (bool, error) {
+	if p.peek() == "!" && p.pos+1 < len(p.args) {
+		p.pos++
+		result, err := p.parseNot()
+		return !result, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *testParser) parsePrimary() (bool, error) {
+	if p.pos >= len(p.args) {
+		return false, fmt.Errorf("argument expected")
+	}
+	arg := p.args[p.pos]
+
+	// A binary operator takes precedence, so that e.g. "( = (" compares strings
+	if p.pos+2 < len(p.args) && slices.Contains(binaryTestOperators, p.args[p.pos+1]) {
+		p.pos += 3
+		return binaryTest(arg, p.args[p.pos-2], p.args[p.pos-1])
+	}
+
+	switch {
+	case arg == "(":
+		p.pos++
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if p.peek() != ")" {
+			return false, fmt.Errorf("missing `)'")
+		}
+		p.pos++
+		return result, nil
+	case slices.Contains(unaryTestOperators, arg) && p.pos+1 < len(p.args):
+		p.pos += 2
+		return unaryTest(arg, p.args[p.pos-1])
+	default:
+		p.pos++
+		return arg != "", nil
+	}
+}
+
+func unaryTest(operator string, operand string) (bool, error) {
+	switch operator {
+	case "-z":
+		return operand == "", nil
+	case "-n":
+		return operand != "", nil
+	case "-r":
+		return syscall.Access(operand, 4) == nil, nil
+	case "-w":
+		return syscall.Access(operand, 2) == nil, nil
+	case "-x":
+		return syscall.Access(operand, 1) == nil, nil
+	case "-L", "-h":
+		info, err := os.Lstat(operand)
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+	}
+
+	info, err := os.Stat(operand)
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+	switch operator {
+	case "-e":
+		return true, nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-g":
+		return mode&os.ModeSetgid != 0, nil
+	case "-u":
+		return mode&os.ModeSetuid != 0, nil
+	case "-k":
+		return mode&os.ModeSticky != 0, nil
+	}
+	return false, fmt.Errorf("%s: unary operator expected", operator)
+}
+
+func binaryTest(left string, operator string, right string) (bool, error) {
+	switch operator {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+	case "-nt", "-ot":
+		leftInfo, leftErr := os.Stat(left)
+		rightInfo, rightErr := os.Stat(right)
+		if operator == "-ot" {
+			leftInfo, leftErr, rightInfo, rightErr = rightInfo, rightErr, leftInfo, leftErr
+		}
+		if leftErr != nil {
+			return false, nil
+		}
+		return rightErr != nil || leftInfo.ModTime().After(rightInfo.ModTime()), nil
+	case "-ef":
+		leftInfo, leftErr := os.Stat(left)
+		rightInfo, rightErr := os.Stat(right)
+		return leftErr == nil && rightErr == nil && os.SameFile(leftInfo, rightInfo), nil
+	}
+
+	a, err := parseTestInteger(left)
+	if err != nil {
+		return false, err
+	}
+	b, err := parseTestInteger(right)
+	if err != nil {
+		return false, err
+	}
+	switch operator {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-lt":
+		return a < b, nil
+	case "-le":
+		return a <= b, nil
+	case "-gt":
+		return a > b, nil
+	default: // "-ge"
+		return a >= b, nil
+	}
+}
+
+func parseTestInteger(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package builtin
+
+import (
+	"os"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+func TestEvaluateTest(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    bool
+		wantErr bool
+	}{
+		// 0 arguments: false
+		{nil, false, false},
+		// 1 argument: true if it is not empty, even if it looks like an operator
+		{[]string{""}, false, false},
+		{[]string{"x"}, true, false},
+		{[]string{"-n"}, true, false},
+		{[]string{"!"}, true, false},
+		{[]string{"("}, true, false},
+		// 2 arguments: "!" negates the test of one argument, otherwise a unary operator
+		{[]string{"!", ""}, true, false},
+		{[]string{"!", "x"}, false, false},
+		{[]string{"-z", ""}, true, false},
+		{[]string{"-z", "x"}, false, false},
+		{[]string{"-e", "/"}, true, false},
+		{[]string{"-d", "/etc/passwd"}, false, false},
+		{[]string{"x", "y"}, false, true},
+		{[]string{"=", "="}, false, true},
+		// 3 arguments: a binary operator in the middle comes first, then "!" and "( )"
+		{[]string{"!", "=", "!"}, true, false},
+		{[]string{"a", "=", "a"}, true, false},
+		{[]string{"a", "!=", "a"}, false, false},
+		{[]string{"!", "!", "x"}, true, false},
+		{[]string{"(", "x", ")"}, true, false},
+		{[]string{"(", "", ")"}, false, false},
+		{[]string{"x", "-a", ""}, false, false},
+		{[]string{"x", "-o", ""}, true, false},
+		{[]string{"!", "-z", "x"}, true, false},
+		{[]string{"x", "y", "z"}, false, true},
+		// 4 arguments: "!" negates the test of three, "( )" encloses the test of two
+		{[]string{"!", "a", "=", "b"}, true, false},
+		{[]string{"!", "", "-a", ""}, true, false},
+		{[]string{"(", "-z", "", ")"}, true, false},
+		// More arguments: -a binds tighter than -o
+		{[]string{"(", "a", "=", "a", ")"}, true, false},
+		{[]string{"!", "(", "x", ")"}, false, false},
+		{[]string{"x", "-a", "x", "-a", ""}, false, false},
+		{[]string{"", "-o", "x", "-a", "x"}, true, false},
+		{[]string{"(", "x", "-o", "", ")", "-a", "x"}, true, false},
+		{[]string{"(", "x", "-a"}, false, true},
+		// Integers and strings
+		{[]string{"1", "-eq", "01"}, true, false},
+		{[]string{"2", "-lt", "10"}, true, false},
+		{[]string{"10", "<", "2"}, true, false},
+		{[]string{"a", "-eq", "1"}, false, true},
+	}
+	for _, test := range tests {
+		got, err := evaluateTest(test.args)
+		if (err != nil) != test.wantErr || (err == nil && got != test.want) {
+			t.Errorf("evaluateTest(%q) = %v, %v; want %v, error %v", test.args, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestHandleTestBracket(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"x", "]"}, 0},
+		{[]string{"]"}, 1},
+		{[]string{"", "]"}, 1},
+		{[]string{"x"}, 2},
+		{nil, 2},
+	}
+	for _, test := range tests {
+		command := &types.Command{Name: "[", Args: test.args, OutputStream: devNull, ErrorStream: devNull}
+		if got := HandleTest(command); got != test.want {
+			t.Errorf("[ %q = %d; want %d", test.args, got, test.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath" // Use filepath for path manipulation
 	"strings"
+	"time"
 )
 
 // Finder is a struct to manage path-related operations.
@@ -12,9 +13,33 @@ type Finder struct {
 	paths []string
 }
 
-// NewFinder creates and initializes a new Finder.
+// NewFinder creates and initializes a new Finder for the directories of a PATH. An empty
+// directory is the current one, and directories that repeat are only searched once, as
+// long PATHs often list the same directory many times.
 func NewFinder(paths []string) *Finder {
-	return &Finder{paths: paths}
+	finder := &Finder{}
+	seen := make(map[string]bool, len(paths))
+	for _, dir := range paths {
+		if dir == "" {
+			dir = "."
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			finder.paths = append(finder.paths, dir)
+		}
+	}
+	return finder
+}
+
+// ChangedSince reports whether a file was added to or removed from any of the searched
+// directories after t.
+func (f *Finder) ChangedSince(t time.Time) bool {
+	for _, dir := range f.paths {
+		if info, err := os.Stat(dir); err == nil && info.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
 }
 
 func IsValidPath(fullPath string) bool {
@@ -61,15 +86,34 @@ func (f *Finder) GetAbsolutePath(path string) string {
 	}
 }
 
-// FindExecutablePath searches for an executable in the configured paths.
+// FindExecutablePath searches for an executable in the configured paths. Directories are
+// skipped, and a file that is not executable is only found if no executable one is, so
+// that running it reports why it can't be run.
 func (f *Finder) FindExecutablePath(command string) (string, bool) {
+	if strings.Contains(command, "/") {
+		// Paths are used as given instead of being searched for
+		return command, f.IsValidPath(command)
+	}
+	candidate := ""
 	for _, dir := range f.paths {
 		fullPath := filepath.Join(dir, command) // Use filepath.Join
-		if f.IsValidPath(fullPath) {
+		if !strings.Contains(fullPath, "/") {
+			fullPath = "./" + fullPath // Found in the current directory, not to be searched for again
+		}
+		if IsExecutableFile(fullPath) {
 			return fullPath, true
 		}
+		if info, err := os.Stat(fullPath); candidate == "" && err == nil && info.Mode().IsRegular() {
+			candidate = fullPath
+		}
 	}
-	return "", false
+	return candidate, candidate != ""
+}
+
+// IsExecutableFile checks if a path is a regular file that can be executed.
+func IsExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
 }
 
 func (f *Finder) GetExecutables() []string {
@@ -91,3 +135,78 @@ func (f *Finder) GetExecutables() []string {
 	}
 	return executables
 }
+
+// CorrectSpelling fixes minor misspellings in the components of a directory path: a
+// transposed, missing, extra or wrong character. Returns the corrected path and true if
+// every component could be matched to an existing directory.
+func CorrectSpelling(path string) (string, bool) {
+	components := strings.Split(path, "/")
+	corrected := make([]string, 0, len(components))
+	for i, component := range components {
+		directory := strings.Join(corrected, "/")
+		if i == 0 && component == "" {
+			corrected = append(corrected, "") // absolute path
+			continue
+		}
+		if directory == "" && i > 0 {
+			directory = "/"
+		} else if directory == "" {
+			directory = "."
+		}
+
+		if component == "" || component == "." || component == ".." || IsValidPath(filepath.Join(directory, component)) {
+			corrected = append(corrected, component)
+			continue
+		}
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return "", false
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.IsDir() && isMisspelling(component, entry.Name()) {
+				corrected = append(corrected, entry.Name())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return strings.Join(corrected, "/"), true
+}
+
+// isMisspelling reports whether typed differs from name by one transposition, insertion,
+// deletion or substitution of a character.
+func isMisspelling(typed string, name string) bool {
+	switch len(typed) - len(name) {
+	case 0:
+		differences := []int{}
+		for i := range len(typed) {
+			if typed[i] != name[i] {
+				differences = append(differences, i)
+			}
+		}
+		if len(differences) == 1 {
+			return true
+		}
+		return len(differences) == 2 && differences[1] == differences[0]+1 &&
+			typed[differences[0]] == name[differences[1]] && typed[differences[1]] == name[differences[0]]
+	case 1:
+		return isOneInsertion(name, typed)
+	case -1:
+		return isOneInsertion(typed, name)
+	}
+	return false
+}
+
+// isOneInsertion reports whether long is short with one character inserted.
+func isOneInsertion(short string, long string) bool {
+	for i := range len(short) {
+		if short[i] != long[i] {
+			return short[i:] == long[i+1:]
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// declarationBuiltins take assignments as arguments, which are expanded like assignments
+// before a command name rather than like other arguments.
+var declarationBuiltins = []string{"declare", "typeset", "export", "readonly"}
+
+// ParseAssignment parses and expands a word of the form name=value, name+=value,
+// name[index]=value or name=(element...). Returns nil if the word is not an assignment.
+// The value is expanded without field splitting or pathname expansion, while the elements
+// of a compound value are expanded like the arguments of a command.
+func ParseAssignment(word string, expander Expander) (*types.Assignment, error) {
+	name, index, appending, value, ok := splitAssignment(word)
+	if !ok {
+		return nil, nil
+	}
+	assignment := &types.Assignment{Name: name, Append: appending}
+	var err error
+	if index != "" {
+		if assignment.Index, err = ExpandString(index, expander, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if index == "" && strings.HasPrefix(value, "(") && matchingParen(value, 0) == len(value)-1 {
+		assignment.Compound = true
+		for _, element := range splitWords(value[1 : len(value)-1]) {
+			if err := addElement(assignment, element, expander); err != nil {
+				return nil, err
+			}
+		}
+		return assignment, nil
+	}
+	assignment.Value, err = ExpandString(value, expander, nil)
+	return assignment, err
+}
+
+// addElement expands an element of a compound value and adds it to the assignment. An
+// element written [index]=value sets the element at that index.
+func addElement(assignment *types.Assignment, element string, expander Expander) error {
+	if closing := strings.Index(element, "]="); strings.HasPrefix(element, "[") && closing > 1 {
+		index, err := ExpandString(element[1:closing], expander, nil)
+		if err != nil {
+			return err
+		}
+		value, err := ExpandString(element[closing+2:], expander, nil)
+		assignment.Elements = append(assignment.Elements, value)
+		assignment.Indices = append(assignment.Indices, index)
+		return err
+	}
+
+	values, err := ExpandWord(element, expander)
+	for _, value := range values {
+		assignment.Elements = append(assignment.Elements, value)
+		assignment.Indices = append(assignment.Indices, "")
+	}
+	return err
+}
+
+// splitAssignment splits an assignment word into the name, the index written in brackets
+// after it, whether it appends with "+=", and the value, all as written.
+func splitAssignment(word string) (string, string, bool, string, bool) {
+	end := 0
+	for end < len(word) && isNameChar(word[end]) {
+		end++
+	}
+	name, index := word[:end], ""
+	if !IsValidName(name) {
+		return "", "", false, "", false
+	}
+	if end < len(word) && word[end] == '[' {
+		closing := strings.IndexByte(word[end:], ']')
+		if closing <= 1 {
+			return "", "", false, "", false
+		}
+		index = word[end+1 : end+closing]
+		end += closing + 1
+	}
+	appending := strings.HasPrefix(word[end:], "+=")
+	if appending {
+		end++
+	}
+	if end >= len(word) || word[end] != '=' {
+		return "", "", false, "", false
+	}
+	return name, index, appending, word[end+1:], true
+}
+
+// SplitSubscript splits a reference to an array element like name[index] into its parts.
+func SplitSubscript(name string) (string, string, bool) {
+	open := strings.IndexByte(name, '[')
+	if open == -1 || !strings.HasSuffix(name, "]") || !IsValidName(name[:open]) {
+		return "", "", false
+	}
+	return name[:open], name[open+1 : len(name)-1], true
+}
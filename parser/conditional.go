@@ -0,0 +1,55 @@
+package parser
+
+import "strings"
+
+// IsConditional reports whether a raw word holds a whole [[ ]] expression.
+func IsConditional(word string) bool {
+	return len(word) >= 5 && strings.HasPrefix(word, "[[") && isBlank(word[2]) && strings.HasSuffix(word, "]]")
+}
+
+// SplitConditional breaks the expression of a [[ ]] word into raw operand and operator
+// tokens. Parentheses, && and || are tokens of their own, except in the regular
+// expression after =~ and in patterns like @(a|b), where parentheses group and may
+// contain blanks.
+func SplitConditional(word string) []string {
+	var (
+		inner   = word[2 : len(word)-2]
+		result  []string
+		current string
+		scanner quoteScanner
+	)
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		inRegex := len(result) > 0 && result[len(result)-1] == "=~"
+		if scanner.balanced() && !scanner.afterDollar && !inRegex {
+			operator := ""
+			if (c == '(' && current == "") || c == ')' {
+				operator = string(c)
+			} else if (c == '&' || c == '|') && i+1 < len(inner) && inner[i+1] == c {
+				operator = inner[i : i+2]
+				i++ // Skip the second character of the operator
+			}
+			if operator != "" {
+				if current != "" {
+					result = append(result, current)
+					current = ""
+				}
+				result = append(result, operator)
+				continue
+			}
+		}
+
+		if scanner.next(inner, i) && isBlank(c) {
+			if current != "" {
+				result = append(result, current)
+			}
+			current = ""
+			continue
+		}
+		current += inner[i : i+1]
+	}
+	if current != "" {
+		result = append(result, current)
+	}
+	return result
+}
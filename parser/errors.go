@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SyntaxError reports input that is not valid shell syntax.
+type SyntaxError struct {
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return "syntax error: " + e.Message
+}
+
+// ExpansionError reports a word that could not be expanded.
+type ExpansionError struct {
+	Word    string
+	Message string
+}
+
+func (e *ExpansionError) Error() string {
+	return e.Word + ": " + e.Message
+}
+
+// AssignmentError reports an assignment of a command without a name that could not be
+// made, e.g. to a readonly variable.
+type AssignmentError struct {
+	Err error
+}
+
+func (e *AssignmentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AssignmentError) Unwrap() error {
+	return e.Err
+}
+
+// redirectionError describes a redirection target that could not be opened.
+func redirectionError(fileName string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	message := err.Error()
+	return fmt.Errorf("%s: %s%s", fileName, strings.ToUpper(message[:1]), message[1:])
+}
@@ -0,0 +1,635 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/shell-starter-go/arithmetic"
+	"github.com/codecrafters-io/shell-starter-go/pattern"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// Expander provides the shell state and the command execution needed during word expansion.
+type Expander interface {
+	// ProcessSubstitution starts list asynchronously and returns the path standing in
+	// for it: a file to read its output from for '<', or to write its input to for '>'.
+	ProcessSubstitution(list string, direction byte) string
+	// CommandSubstitution runs list and returns its output without trailing newlines.
+	CommandSubstitution(list string) string
+	// Parameter returns the value of a named, positional or special parameter.
+	Parameter(name string) (string, bool)
+	// PositionalParameters returns $1 to $N, which "$@" and "$*" expand to.
+	PositionalParameters() []string
+	// Element returns an element of an array, as in ${name[index]}. The index is expanded
+	// but not yet evaluated, as only indexed arrays take arithmetic indices.
+	Element(name string, index string) (string, bool)
+	// Elements returns the indices and values of an array in order, as in ${!name[@]}
+	// and ${name[@]}. A scalar with a value is an array with the single index 0.
+	Elements(name string) ([]string, []string)
+	// Option reports whether a shell option like nounset or noglob is turned on.
+	Option(name string) bool
+	// SetVariable assigns a value to a variable, as done by arithmetic expansion.
+	SetVariable(name string, value string) error
+	// Assign makes an assignment of a command without a name as soon as it is expanded,
+	// so that the assignments after it see its value.
+	Assign(assignment types.Assignment) error
+	// Descriptor returns a new file for a descriptor above 2 that the shell keeps open,
+	// as set up by exec, for redirections like >&3. The caller closes it.
+	Descriptor(fd int) (*os.File, bool)
+}
+
+// field is one field of an expanded word.
+type field struct {
+	text    string // the expanded text
+	pattern string // the same text with its quoted parts escaped, for use as a pattern
+}
+
+// fieldBuilder collects the fields a single word expands to.
+type fieldBuilder struct {
+	fields    []field
+	current   field
+	started   bool                // current field exists even when empty, e.g. after ""
+	quote     func(string) string // escapes quoted text in the pattern of a field
+	splitting bool                // unquoted expansions are split at the characters of ifs
+	ifs       string
+}
+
+func (f *fieldBuilder) add(s string) {
+	f.current.text += s
+	f.current.pattern += s
+	if s != "" {
+		f.started = true
+	}
+}
+
+// addQuoted adds text that was quoted or escaped in the word.
+func (f *fieldBuilder) addQuoted(s string) {
+	f.current.text += s
+	f.current.pattern += f.quote(s)
+	if s != "" {
+		f.started = true
+	}
+}
+
+// addSplit adds the result of an unquoted expansion, which is split into fields at the
+// characters of IFS. Runs of IFS whitespace delimit fields and are ignored around other
+// IFS characters, each of which delimits a field, possibly an empty one.
+func (f *fieldBuilder) addSplit(s string) {
+	if !f.splitting {
+		f.add(s)
+		return
+	}
+	isDelimiter := func(c byte) bool { return strings.IndexByte(f.ifs, c) >= 0 }
+	isWhitespace := func(c byte) bool { return isDelimiter(c) && isBlank(c) }
+
+	for i := 0; i < len(s); {
+		if !isDelimiter(s[i]) {
+			start := i
+			for i < len(s) && !isDelimiter(s[i]) {
+				i++
+			}
+			f.add(s[start:i])
+			continue
+		}
+
+		for i < len(s) && isWhitespace(s[i]) {
+			i++
+		}
+		if i < len(s) && isDelimiter(s[i]) && !isWhitespace(s[i]) {
+			f.started = true // a field ended by a delimiter exists even when empty
+			for i++; i < len(s) && isWhitespace(s[i]); i++ {
+			}
+		}
+		f.split()
+	}
+}
+
+// addText adds literal or expanded text, which is quoted when inside double quotes.
+func (f *fieldBuilder) addText(s string, quoted bool) {
+	if quoted {
+		f.addQuoted(s)
+	} else {
+		f.add(s)
+	}
+}
+
+// split ends the current field, dropping it if nothing contributed to it.
+func (f *fieldBuilder) split() {
+	if f.started {
+		f.fields = append(f.fields, f.current)
+	}
+	f.current = field{}
+	f.started = false
+}
+
+// ExpandWord performs parameter expansion, command substitution, arithmetic expansion,
+// process substitution, field splitting, pathname expansion and quote removal on a raw
+// word. A word usually yields one field, but "$@", unquoted expansions split at IFS and
+// patterns matching file names can yield any number of them.
+func ExpandWord(word string, expander Expander) ([]string, error) {
+	fields, err := expandWord(word, expander, pattern.Escape, true)
+	if err != nil {
+		return nil, err
+	}
+
+	options := globOptions(expander)
+	var result []string
+	for _, field := range fields {
+		if !expander.Option("noglob") && pattern.HasMeta(field.pattern, options) {
+			matches := pattern.Glob(field.pattern, options)
+			if len(matches) > 0 || expander.Option("nullglob") {
+				result = append(result, matches...)
+				continue
+			}
+		}
+		result = append(result, field.text) // a pattern without matches is kept as it is
+	}
+	return result, nil
+}
+
+// globOptions returns how pathname expansion matches patterns, as set by shell options.
+func globOptions(expander Expander) pattern.Options {
+	return pattern.Options{
+		ExtGlob:  expander.Option("extglob"),
+		NoCase:   expander.Option("nocaseglob"),
+		DotGlob:  expander.Option("dotglob"),
+		GlobStar: expander.Option("globstar"),
+	}
+}
+
+// ExpandString expands a word into a single string without field splitting or pathname
+// expansion, as done for the operands of [[ ]]. Quoted text is passed through quote, if
+// given, so that it stays literal when the result is used as a pattern.
+func ExpandString(word string, expander Expander, quote func(string) string) (string, error) {
+	patterns := quote != nil
+	if !patterns {
+		quote = pattern.Escape
+	}
+	fields, err := expandWord(word, expander, quote, false)
+	var result []string
+	for _, field := range fields {
+		if patterns {
+			result = append(result, field.pattern)
+		} else {
+			result = append(result, field.text)
+		}
+	}
+	return strings.Join(result, " "), err
+}
+
+func expandWord(word string, expander Expander, quote func(string) string, splitting bool) ([]field, error) {
+	ifs, ok := expander.Parameter("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	var (
+		fields         = fieldBuilder{quote: quote, splitting: splitting && ifs != "", ifs: ifs}
+		inSingleQuotes bool
+		inDoubleQuotes bool
+	)
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		switch {
+		case inSingleQuotes:
+			if c == '\'' {
+				inSingleQuotes = false
+			} else {
+				fields.addQuoted(word[i : i+1])
+			}
+		case c == '\\' && i+1 < len(word):
+			next := word[i+1]
+			if inDoubleQuotes && !strings.ContainsRune("$`\"\\\n", rune(next)) {
+				fields.addQuoted(word[i : i+1]) // backslash is literal before other characters in double quotes
+				continue
+			}
+			if next != '\n' { // escaped newline is a line continuation
+				fields.addQuoted(word[i+1 : i+2])
+			}
+			i++
+		case c == '\'' && !inDoubleQuotes:
+			inSingleQuotes = true
+			fields.started = true
+		case c == '"' && !inDoubleQuotes && isQuotedEmptyAt(word[i:], expander):
+			// "$@" without positional parameters expands to no field at all
+			i += strings.IndexByte(word[i+1:], '"') + 1
+		case c == '"':
+			inDoubleQuotes = !inDoubleQuotes
+			fields.started = true
+		case c == '$' && i+1 < len(word) && word[i+1] == '(':
+			end, err := expandSubstitution(word, i, inDoubleQuotes, &fields, expander)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '`':
+			end := closingBacktick(word, i)
+			if end == -1 {
+				fields.addText(word[i:], inDoubleQuotes)
+				i = len(word)
+				continue
+			}
+			output := expander.CommandSubstitution(unescapeBackticks(word[i+1 : end]))
+			addExpansion(&fields, output, inDoubleQuotes)
+			i = end
+		case c == '$':
+			end, err := expandParameter(word, i, inDoubleQuotes, &fields, expander)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case (c == '<' || c == '>') && !inDoubleQuotes && i+1 < len(word) && word[i+1] == '(':
+			end := matchingParen(word, i+1)
+			if end == -1 {
+				fields.add(word[i:])
+				i = len(word)
+				continue
+			}
+			fields.add(expander.ProcessSubstitution(word[i+2:end], c))
+			i = end
+		default:
+			fields.addText(word[i:i+1], inDoubleQuotes)
+		}
+	}
+	fields.split()
+	return fields.fields, nil
+}
+
+// addExpansion adds the result of an expansion, which is split into fields unless quoted.
+func addExpansion(fields *fieldBuilder, value string, quoted bool) {
+	if quoted {
+		fields.addQuoted(value)
+	} else {
+		fields.addSplit(value)
+	}
+}
+
+// expandSubstitution expands the $(list) command substitution or $((expression))
+// arithmetic expansion starting at word[i] and returns the index of its last byte.
+func expandSubstitution(word string, i int, quoted bool, fields *fieldBuilder, expander Expander) (int, error) {
+	end := matchingParen(word, i+1)
+	if end == -1 {
+		fields.addText(word[i:], quoted)
+		return len(word), nil
+	}
+
+	if word[i+2] == '(' && matchingParen(word, i+2) == end-1 {
+		expression, err := ExpandString(word[i+3:end-1], expander, nil)
+		if err != nil {
+			return end, err
+		}
+		value, err := arithmetic.Evaluate(expression, expander)
+		if err != nil {
+			return end, &ExpansionError{Word: strings.TrimSpace(expression), Message: err.Error()}
+		}
+		addExpansion(fields, strconv.FormatInt(value, 10), quoted)
+		return end, nil
+	}
+
+	addExpansion(fields, expander.CommandSubstitution(word[i+2:end]), quoted)
+	return end, nil
+}
+
+// closingBacktick returns the index of the '`' ending the `list` command substitution
+// that starts at word[open], or -1.
+func closingBacktick(word string, open int) int {
+	for i := open + 1; i < len(word); i++ {
+		switch word[i] {
+		case '\\':
+			i++
+		case '`':
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeBackticks removes the backslashes that escape $, ` and \ inside `list`.
+func unescapeBackticks(list string) string {
+	var result strings.Builder
+	for i := 0; i < len(list); i++ {
+		if list[i] == '\\' && i+1 < len(list) && strings.IndexByte("$`\\", list[i+1]) >= 0 {
+			i++
+		}
+		result.WriteByte(list[i])
+	}
+	return result.String()
+}
+
+// isQuotedEmptyAt reports whether word starts with "$@", "${@}" or "${name[@]}" in double
+// quotes that expands to no field at all, because there are no parameters or elements.
+func isQuotedEmptyAt(word string, expander Expander) bool {
+	closing := strings.IndexByte(word[1:], '"')
+	if closing == -1 {
+		return false
+	}
+	switch inner := word[1 : closing+1]; {
+	case inner == "$@" || inner == "${@}":
+		return len(expander.PositionalParameters()) == 0
+	case strings.HasPrefix(inner, "${") && strings.HasSuffix(inner, "[@]}") && IsValidName(inner[2:len(inner)-4]):
+		_, values := expander.Elements(inner[2 : len(inner)-4])
+		return len(values) == 0
+	}
+	return false
+}
+
+// expandParameter expands the parameter reference starting at the '$' in word[i] and
+// returns the index of its last byte.
+func expandParameter(word string, i int, quoted bool, fields *fieldBuilder, expander Expander) (int, error) {
+	name, end := parameterName(word, i+1)
+	if name == "" {
+		fields.add("$") // not a parameter, keep the dollar sign
+		return i, nil
+	}
+	if word[i+1] == '{' {
+		return end, expandBraced(name, quoted, fields, expander)
+	}
+
+	if name == "@" || name == "*" {
+		addList(expander.PositionalParameters(), name == "*", quoted, fields, expander)
+		return end, nil
+	}
+	if err := checkSet(name, expander); err != nil {
+		return end, err
+	}
+	value, _ := expander.Parameter(name)
+	addExpansion(fields, value, quoted)
+	return end, nil
+}
+
+// expandBraced expands the expression inside ${...}: a parameter like name, name[index],
+// name[@] or @, optionally preceded by # for its length or ! for its indices or the
+// parameter it names, and optionally followed by :offset[:length] to take a part of it.
+func expandBraced(expression string, quoted bool, fields *fieldBuilder, expander Expander) error {
+	badSubstitution := &ExpansionError{Word: "${" + expression + "}", Message: "bad substitution"}
+	operator := byte(0)
+	if len(expression) > 1 && (expression[0] == '#' || expression[0] == '!') {
+		operator, expression = expression[0], expression[1:]
+	}
+	reference, rest := splitReference(expression)
+	if reference == "" || (rest != "" && (rest[0] != ':' || strings.IndexByte("-=+?", rest[min(1, len(rest)-1)]) >= 0)) {
+		return badSubstitution
+	}
+
+	// all is set for references to every element or positional parameter, which expand
+	// to a list; joined is set for the forms with '*' that join it into one word
+	var values []string
+	all, joined := false, false
+	name, index, subscripted := SplitSubscript(reference)
+	switch {
+	case reference == "@" || reference == "*":
+		values, all, joined = expander.PositionalParameters(), true, reference == "*"
+		if rest != "" {
+			zero, _ := expander.Parameter("0")
+			values = append([]string{zero}, values...) // offsets count from $0
+		}
+	case subscripted && (index == "@" || index == "*"):
+		var indices []string
+		indices, values = expander.Elements(name)
+		all, joined = true, index == "*"
+		if operator == '!' {
+			values, operator = indices, 0
+		}
+	case subscripted:
+		expandedIndex, err := ExpandString(index, expander, nil)
+		if err != nil {
+			return err
+		}
+		value, set := expander.Element(name, expandedIndex)
+		if !set && expander.Option("nounset") {
+			return &ExpansionError{Word: reference, Message: "unbound variable"}
+		}
+		values = []string{value}
+	case isValidParameter(reference):
+		if err := checkSet(reference, expander); err != nil {
+			return err
+		}
+		value, _ := expander.Parameter(reference)
+		values = []string{value}
+	default:
+		return badSubstitution
+	}
+
+	switch {
+	case operator == '!':
+		// ${!name} expands the parameter whose name is the value of name
+		target := values[0]
+		if _, _, ok := SplitSubscript(target); !ok && !isValidParameter(target) {
+			return &ExpansionError{Word: reference, Message: "invalid indirect expansion"}
+		}
+		return expandBraced(target+rest, quoted, fields, expander)
+	case operator == '#':
+		if rest != "" {
+			return badSubstitution
+		}
+		length := len(values)
+		if !all {
+			length = utf8.RuneCountInString(values[0])
+		}
+		fields.add(strconv.Itoa(length))
+		return nil
+	case rest != "":
+		offset, length, hasLength, err := parseSlice(rest[1:], expander)
+		if err != nil {
+			return err
+		}
+		if all {
+			values, err = sliceList(values, offset, length, hasLength)
+		} else {
+			values[0], err = substring(values[0], offset, length, hasLength)
+		}
+		if err != nil {
+			return &ExpansionError{Word: strings.TrimSpace(rest[1:]), Message: err.Error()}
+		}
+	}
+
+	if all {
+		addList(values, joined, quoted, fields, expander)
+	} else {
+		addExpansion(fields, values[0], quoted)
+	}
+	return nil
+}
+
+// splitReference splits a parameter reference like name[index] or 10 from the operators
+// that follow it.
+func splitReference(expression string) (string, string) {
+	if expression == "" {
+		return "", ""
+	}
+	end := 1
+	switch c := expression[0]; {
+	case isNameStart(c):
+		for end < len(expression) && isNameChar(expression[end]) {
+			end++
+		}
+		if end < len(expression) && expression[end] == '[' {
+			closing := strings.IndexByte(expression[end:], ']')
+			if closing == -1 {
+				return "", ""
+			}
+			end += closing + 1
+		}
+	case c >= '0' && c <= '9':
+		for end < len(expression) && expression[end] >= '0' && expression[end] <= '9' {
+			end++
+		}
+	case strings.IndexByte("@*#?-$!", c) == -1:
+		return "", ""
+	}
+	return expression[:end], expression[end:]
+}
+
+// parseSlice evaluates the offset and the optional length of ${parameter:offset:length}.
+func parseSlice(slice string, expander Expander) (int, int, bool, error) {
+	offsetExpression, lengthExpression, hasLength := strings.Cut(slice, ":")
+	offset, err := evaluateExpression(offsetExpression, expander)
+	if err != nil || !hasLength {
+		return offset, 0, false, err
+	}
+	length, err := evaluateExpression(lengthExpression, expander)
+	return offset, length, true, err
+}
+
+// evaluateExpression expands and evaluates an arithmetic expression.
+func evaluateExpression(expression string, expander Expander) (int, error) {
+	expanded, err := ExpandString(expression, expander, nil)
+	if err != nil {
+		return 0, err
+	}
+	value, err := arithmetic.Evaluate(expanded, expander)
+	if err != nil {
+		return 0, &ExpansionError{Word: strings.TrimSpace(expanded), Message: err.Error()}
+	}
+	return int(value), nil
+}
+
+// sliceList returns length values from offset on, counting from the end for a negative
+// offset.
+func sliceList(values []string, offset int, length int, hasLength bool) ([]string, error) {
+	if offset < 0 {
+		offset += len(values)
+	}
+	if offset < 0 || offset > len(values) {
+		return nil, nil
+	}
+	if !hasLength {
+		return values[offset:], nil
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%d: substring expression < 0", length)
+	}
+	return values[offset:min(offset+length, len(values))], nil
+}
+
+// substring returns length characters of value from offset on. A negative offset counts
+// from the end, and a negative length leaves out that many characters at the end.
+func substring(value string, offset int, length int, hasLength bool) (string, error) {
+	runes := []rune(value)
+	if offset < 0 {
+		offset += len(runes)
+	}
+	if offset < 0 || offset > len(runes) {
+		return "", nil
+	}
+	end := len(runes)
+	if hasLength && length >= 0 {
+		end = min(offset+length, len(runes))
+	} else if hasLength {
+		end += length
+		if end < offset {
+			return "", fmt.Errorf("%d: substring expression < 0", length)
+		}
+	}
+	return string(runes[offset:end]), nil
+}
+
+// checkSet returns an error for a parameter that is not set if the nounset option is on.
+// "$@" and "$*" are always allowed, even without positional parameters.
+func checkSet(name string, expander Expander) error {
+	if name == "@" || name == "*" || !expander.Option("nounset") {
+		return nil
+	}
+	if _, ok := expander.Parameter(name); !ok {
+		return &ExpansionError{Word: name, Message: "unbound variable"}
+	}
+	return nil
+}
+
+// parameterName reads the name of a parameter reference starting right after a '$'.
+// It returns the name and the index of its last byte, or an empty name if there is none.
+// For ${...} the name is everything between the braces.
+func parameterName(word string, start int) (string, int) {
+	if start >= len(word) {
+		return "", start
+	}
+
+	c := word[start]
+	switch {
+	case c == '{':
+		closing := strings.IndexByte(word[start:], '}')
+		if closing == -1 {
+			return "", start
+		}
+		return word[start+1 : start+closing], start + closing
+	case strings.IndexByte("@*#?-$!", c) >= 0 || (c >= '0' && c <= '9'):
+		return string(c), start
+	case isNameStart(c):
+		end := start
+		for end+1 < len(word) && isNameChar(word[end+1]) {
+			end++
+		}
+		return word[start : end+1], end
+	}
+	return "", start
+}
+
+// addList adds the values of "$@" or "${name[@]}", one field each, or joined by the
+// first character of IFS for the quoted '*' forms.
+func addList(values []string, joined bool, quoted bool, fields *fieldBuilder, expander Expander) {
+	if joined && quoted {
+		separator := " "
+		if ifs, ok := expander.Parameter("IFS"); ok {
+			separator = ifs[:min(1, len(ifs))]
+		}
+		fields.addQuoted(strings.Join(values, separator))
+		return
+	}
+
+	for j, value := range values {
+		if j > 0 {
+			fields.split()
+		}
+		addExpansion(fields, value, quoted)
+		if quoted {
+			fields.started = true // "$@" keeps empty parameters
+		}
+	}
+}
+
+// IsValidName reports whether name can be used as a variable name.
+func IsValidName(name string) bool {
+	if name == "" || !isNameStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isNameChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidParameter(name string) bool {
+	return IsValidName(name) || isNumber(name) || (len(name) == 1 && strings.Contains("@*#?-$!", name))
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
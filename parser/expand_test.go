@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/pattern"
+)
+
+func TestAddSplit(t *testing.T) {
+	tests := []struct {
+		ifs  string
+		text string
+		want []string
+	}{
+		// Runs of IFS whitespace delimit fields and are dropped at both ends
+		{" \t\n", "  a  b ", []string{"a", "b"}},
+		{" \t\n", "a\t\n b", []string{"a", "b"}},
+		{"\t", "a\t\tb", []string{"a", "b"}},
+		{" ", "   ", nil},
+		// Every other IFS character delimits a field, which may be empty
+		{":", "a::b", []string{"a", "", "b"}},
+		{":", ":a", []string{"", "a"}},
+		{":", "a:", []string{"a"}},
+		{":", "a::", []string{"a", ""}},
+		{":", ":", []string{""}},
+		// Whitespace around other IFS characters belongs to them
+		{" :", "a : b", []string{"a", "b"}},
+		{" :", "a :: b", []string{"a", "", "b"}},
+		{" :", " : a", []string{"", "a"}},
+		{" :", " a: ", []string{"a"}},
+		{" :", "  ", nil},
+		// Characters not in IFS are kept, and an empty IFS splits nothing
+		{":", "a b", []string{"a b"}},
+		{"", "a b", []string{"a b"}},
+	}
+	for _, test := range tests {
+		f := &fieldBuilder{quote: pattern.Escape, splitting: true, ifs: test.ifs}
+		f.addSplit(test.text)
+		f.split()
+		if got := fieldTexts(f.fields); !slices.Equal(got, test.want) {
+			t.Errorf("splitting %q with IFS %q = %q; want %q", test.text, test.ifs, got, test.want)
+		}
+	}
+}
+
+func TestAddSplitJoinsSurroundingText(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{" a b ", []string{"x", "a", "b", "y"}},
+		{"a b", []string{"xa", "by"}},
+		{"", []string{"xy"}},
+		{" ", []string{"x", "y"}},
+	}
+	for _, test := range tests {
+		f := &fieldBuilder{quote: pattern.Escape, splitting: true, ifs: " \t\n"}
+		f.add("x")
+		f.addSplit(test.text)
+		f.add("y")
+		f.split()
+		if got := fieldTexts(f.fields); !slices.Equal(got, test.want) {
+			t.Errorf("x%sy split into %q; want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestAddSplitWithoutSplitting(t *testing.T) {
+	f := &fieldBuilder{quote: pattern.Escape, ifs: " "}
+	f.addSplit(" a  b ")
+	f.split()
+	if got, want := fieldTexts(f.fields), []string{" a  b "}; !slices.Equal(got, want) {
+		t.Errorf("unsplit text = %q; want %q", got, want)
+	}
+}
+
+func fieldTexts(fields []field) []string {
+	var texts []string
+	for _, field := range fields {
+		texts = append(texts, field.text)
+	}
+	return texts
+}
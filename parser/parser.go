@@ -1,75 +1,110 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/codecrafters-io/shell-starter-go/types" // Import the shell package to use its Command struct
 )
 
-func splitSingleWordByRedirects(input string) []string {
-	var result []string
-	var current string
+// ListItem is one pipeline of a command list together with the operator that follows it.
+type ListItem struct {
+	Pipeline string
+	Operator string // ";", "&&", "||", or "" after the last pipeline
+	Line     int    // Line of the input the pipeline ends on, counting from 0
+	Negated  bool   // The pipeline was preceded by "!", which inverts its status
+}
+
+// splitList breaks input into pipelines at unquoted ;, &&, || and newlines.
+// A newline right after a pipe symbol continues the pipeline instead.
+func splitList(input string) ([]ListItem, error) {
+	var (
+		result  []ListItem
+		current string
+		scanner quoteScanner
+		inPipe  bool // last unquoted non-blank character was a '|'
+		line    int  // line of input[i]
+		last    int  // line of the last non-blank character of the current pipeline
+	)
 	for i := 0; i < len(input); i++ {
-		if input[i] == '>' || input[i] == '<' {
-			if current != "" {
-				result = append(result, current)
+		c := input[i]
+		if i > 0 && input[i-1] == '\n' {
+			line++
+		}
+		operator := ""
+		if scanner.next(input, i) {
+			if c == ';' || (c == '\n' && !inPipe) {
+				operator = ";"
+			} else if (c == '&' || c == '|') && i+1 < len(input) && input[i+1] == c {
+				operator = input[i : i+2]
+				i++ // Skip the second character of the operator
 			}
-			if current != "1" && current != "2" {
-				result = append(result, "1") // Default output stream if not specified
+			if !isBlank(c) {
+				inPipe = c == '|' && operator == ""
 			}
-			current = ""
-			// Handle multiple redirects
-			if i+1 < len(input) && input[i] == input[i+1] {
-				result = append(result, string(input[i])+string(input[i+1]))
-				i++ // Skip the next character as it's part of the redirect
-			} else {
-				result = append(result, string(input[i]))
+		}
+		if operator == "" {
+			if !isBlank(c) {
+				last = line
 			}
-		} else {
-			current += string(input[i])
+			current += input[i : i+1]
+			continue
+		}
+
+		if strings.TrimSpace(current) == "" {
+			if operator == ";" && c == '\n' {
+				continue // blank lines separate nothing
+			}
+			return nil, &SyntaxError{Message: fmt.Sprintf("unexpected token `%s'", operator)}
 		}
+		pipeline, negated := cutNegation(current)
+		result = append(result, ListItem{Pipeline: pipeline, Operator: operator, Line: last, Negated: negated})
+		current = ""
 	}
-	if current != "" {
-		result = append(result, current)
+
+	if strings.TrimSpace(current) != "" {
+		pipeline, negated := cutNegation(current)
+		result = append(result, ListItem{Pipeline: pipeline, Line: last, Negated: negated})
+	} else if len(result) > 0 && result[len(result)-1].Operator != ";" {
+		return nil, &SyntaxError{Message: "unexpected end of file"}
+	}
+	return result, nil
+}
+
+// cutNegation removes the "!" words in front of a pipeline and reports whether there was
+// an odd number of them, which negates its status.
+func cutNegation(pipeline string) (string, bool) {
+	negated := false
+	for {
+		rest := strings.TrimLeft(pipeline, " \t\n")
+		if !strings.HasPrefix(rest, "!") || (len(rest) > 1 && !isBlank(rest[1])) {
+			return pipeline, negated
+		}
+		pipeline, negated = rest[1:], !negated
 	}
-	return result
 }
 
 func splitByPipes(input string) []string {
 	var (
-		result         []string
-		current        string
-		inSingleQuotes bool
-		inDoubleQuotes bool
-		escaped        bool
+		result  []string
+		current string
+		scanner quoteScanner
 	)
 	for i := range len(input) {
 		c := input[i]
-		switch {
-		case escaped:
-			current += string(c)
-			escaped = false
-		case c == '\\' && !inSingleQuotes:
-			escaped = true
-			current += string(c) // keep escape character in first token
-		case c == '\'' && !inDoubleQuotes:
-			inSingleQuotes = !inSingleQuotes
-			current += string(c)
-		case c == '"' && !inSingleQuotes:
-			inDoubleQuotes = !inDoubleQuotes
-			current += string(c)
-		case c == '|' && !inSingleQuotes && !inDoubleQuotes:
-			// unquoted pipe – split here
+		if scanner.next(input, i) && c == '|' && !strings.HasSuffix(current, ">") {
+			// unquoted pipe – split here, unless it is part of the >| operator
 			if current != "" {
 				result = append(result, current)
 				current = ""
 			}
-		default:
-			current += string(c)
+			continue
 		}
+		current += input[i : i+1]
 	}
 	if current != "" {
 		result = append(result, current)
@@ -77,159 +112,307 @@ func splitByPipes(input string) []string {
 	return result
 }
 
-// The first token (command) retains all quotes/escapes; subsequent tokens are stripped and unescaped before redirect splitting.
-func splitByQuotesAndRedirects(input string) []string {
-	input = strings.TrimLeftFunc(input, unicode.IsSpace)
+// splitWords breaks a command into raw words. Quotes and escapes are kept intact so that
+// ExpandWord can honour them later; unquoted redirection operators become separate tokens
+// preceded by the file descriptor they apply to, e.g. "2>>err" becomes "2", ">>", "err"
+// and "2>&1" becomes "2", ">&", "1".
+func splitWords(input string) []string {
 	var (
-		result         []string
-		current        string
-		inSingleQuotes bool
-		inDoubleQuotes bool
-		escaped        bool
-		firstDone      bool
+		result  []string
+		current string
+		scanner quoteScanner
 	)
-	runes := []rune(input)
-	for i, r := range runes {
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		if !scanner.next(input, i) {
+			current += input[i : i+1]
+			continue
+		}
 
-		// fmt.Printf("Processing rune: %c (index %d)\n", r, i) // Debugging output
 		switch {
-		case escaped:
-			// fmt.Printf("Escaped character: ~%c~\n", r) // Debugging output
-			current += string(r)
-			escaped = false
-		case r == '\\' && !inSingleQuotes:
-			if !inDoubleQuotes {
-				escaped = true
-				if !firstDone {
-					current += string(r) // keep escape character in first token
-				}
-			} else {
-				if i+1 < len(input) && (input[i+1] == ' ' ||
-					input[i+1] == '\t' ||
-					input[i+1] == '|' ||
-					input[i+1] == '<' ||
-					input[i+1] == '>' ||
-					input[i+1] == '\\' ||
-					(!inDoubleQuotes && input[i+1] == '\'') ||
-					input[i+1] == '"' ||
-					input[i+1] == '\n') {
-					escaped = true
-					if !firstDone {
-						current += string(r)
-					}
-				} else {
-					current += string(r)
-				}
-			}
-		case r == '\'' && !inDoubleQuotes:
-			inSingleQuotes = !inSingleQuotes
-			if !firstDone {
-				current += string(r) // keep escape character in first token
+		case isBlank(c):
+			if current != "" {
+				result = append(result, current)
 			}
-		case r == '"' && !inSingleQuotes:
-			inDoubleQuotes = !inDoubleQuotes
-			if !firstDone {
-				current += string(r) // keep escape character in first token
+			current = ""
+		case (c == '<' || c == '>') && i+1 < len(input) && input[i+1] == '(':
+			// process substitution stays part of the word
+			current += input[i : i+1]
+		case c == '<' || c == '>':
+			fd := "1" // Default output stream if not specified
+			if c == '<' {
+				fd = "0"
 			}
-		case unicode.IsSpace(r) && !inSingleQuotes && !inDoubleQuotes:
-			if current != "" {
-				if !firstDone {
-					// first token: keep quotes
-					result = append(result, current)
-					firstDone = true
-				} else {
-					// strip surrounding quotes for subsequent tokens
-					stripped := StripSurroundingQuotes(current)
-					result = append(result, splitSingleWordByRedirects(stripped)...)
-				}
+			if isNumber(current) {
+				fd = current
+			} else if current != "" {
+				result = append(result, current)
 			}
 			current = ""
+
+			operator := string(c)
+			if i+1 < len(input) && (input[i+1] == '&' || (c == '>' && (input[i+1] == '>' || input[i+1] == '|'))) {
+				operator += string(input[i+1])
+				i++ // Skip the next character as it's part of the redirect
+			}
+			result = append(result, fd, operator)
 		default:
-			current += string(r)
+			current += input[i : i+1]
 		}
-		// flush at end
-		if i == len(runes)-1 && current != "" {
-			if !firstDone {
-				result = append(result, current)
-			} else {
-				stripped := StripSurroundingQuotes(current)
-				result = append(result, splitSingleWordByRedirects(stripped)...)
-			}
+	}
+	if current != "" {
+		result = append(result, current)
+	}
+	return result
+}
+
+// IsComplete reports whether input can be run as it is. Input is incomplete while a quote,
+// $(...) or similar construct is still open, when the last line ends with a backslash, or
+// when it ends with a |, && or || that needs another command.
+func IsComplete(input string) bool {
+	var (
+		scanner      quoteScanner
+		lastUnquoted = -1 // index of the last unquoted non-blank character
+	)
+	for i := 0; i < len(input); i++ {
+		if i == len(input)-1 && scanner.escaped && input[i] == '\n' {
+			return false // backslash-newline continues on the next line
+		}
+		if scanner.next(input, i) && !isBlank(input[i]) {
+			lastUnquoted = i
 		}
+	}
+	if !scanner.balanced() {
+		return false
+	}
 
-		// fmt.Printf("Current token: ~%s~\n", current) // Debugging output
+	trimmed := strings.TrimRight(input, " \t\n")
+	if lastUnquoted != len(trimmed)-1 {
+		return true
 	}
-	return result
+	return !strings.HasSuffix(trimmed, "|") && !strings.HasSuffix(trimmed, "&&")
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
 }
 
-// stripSurroundingQuotes removes matching single or double quotes around a word.
-func StripSurroundingQuotes(s string) string {
-	if len(s) >= 2 {
-		if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
-			return s[1 : len(s)-1]
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
 		}
 	}
-	return s
+	return true
+}
+
+func isRedirectOperator(word string) bool {
+	return word == ">" || word == ">>" || word == ">|" || word == "<" || word == ">&" || word == "<&"
 }
 
-func ParseCommand(input string, curInputStream *os.File, curOutputStream *os.File) *types.Command {
+// ParseCommand splits a command into words, expands them and opens its redirections, which
+// apply on top of the given standard streams. It returns a nil command if nothing is left
+// to run.
+func ParseCommand(input string, curInputStream *os.File, curOutputStream *os.File, curErrorStream *os.File, expander Expander) (*types.Command, error) {
 	// Split the input into words
-	words := splitByQuotesAndRedirects(input)
-	// fmt.Printf("RAW  (%d bytes): %s\n", len(input), input)
-	// fmt.Printf("GO-LIT: %#v\n", words)
+	words := splitWords(input)
 
 	// Handle empty input
 	if len(words) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// The first word is the command name, the rest are arguments
-	commandName := words[0]
-	var args []string
+	var fields []string
+	var assignments []types.Assignment
+	declarations := make(map[int]types.Assignment)
+	var inputStream *os.File = nil
 	var outputStream *os.File = nil
 	var errorStream *os.File = nil
-	var notIncludeInArgs = make([]bool, len(words))
-
-	for i := 1; i < len(words); i++ {
-		if words[i] == ">" || words[i] == ">>" {
-			fileOpenBitMask := os.O_CREATE | os.O_WRONLY
-			if words[i] == ">>" {
-				fileOpenBitMask |= os.O_APPEND
-			}
+	descriptors := make(map[int]*os.File)
+	// current returns the file a descriptor refers to at this point of the redirections
+	current := func(fd int) (*os.File, bool) {
+		file, ok := descriptors[fd]
+		switch {
+		case ok:
+			return file, file != nil
+		case fd == 0:
+			return curInputStream, true
+		case fd == 1:
+			return curOutputStream, true
+		case fd == 2:
+			return curErrorStream, true
+		}
+		return expander.Descriptor(fd)
+	}
 
-			if i+1 < len(words) {
-				if words[i-1] == "1" {
-					outputStream, _ = os.OpenFile(words[i+1], fileOpenBitMask, 0644)
-				} else if words[i-1] == "2" {
-					errorStream, _ = os.OpenFile(words[i+1], fileOpenBitMask, 0644)
-				} else {
-					fmt.Println("something went wrong")
+	// Without a name the assignments change the shell and are made left to right
+	assignOnly := onlyAssignments(words)
+	for i := 0; i < len(words); i++ {
+		if i == 0 && IsConditional(words[i]) {
+			// Operands of [[ ]] are expanded while the expression is evaluated
+			fields = append([]string{"[["}, SplitConditional(words[i])...)
+			continue
+		}
+		if i+1 >= len(words) || !isRedirectOperator(words[i+1]) {
+			if len(fields) == 0 || slices.Contains(declarationBuiltins, fields[0]) {
+				assignment, err := ParseAssignment(words[i], expander)
+				if err != nil {
+					return nil, err
 				}
+				if assignment != nil && len(fields) == 0 {
+					if assignOnly {
+						if err := expander.Assign(*assignment); err != nil {
+							return nil, &AssignmentError{Err: err}
+						}
+					}
+					assignments = append(assignments, *assignment)
+					continue
+				}
+				if assignment != nil {
+					declarations[len(fields)-1] = *assignment
+					fields = append(fields, assignment.String())
+					continue
+				}
+			}
+			expanded, err := ExpandWord(words[i], expander)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, expanded...)
+			continue
+		}
 
-				notIncludeInArgs[i-1] = true // Mark the previous word as not an argument
-				notIncludeInArgs[i] = true   // Mark the current word as not an argument
-				notIncludeInArgs[i+1] = true // Mark the next word (filename) as not an argument
+		fd, operator := words[i], words[i+1]
+		if i+2 >= len(words) {
+			return nil, &SyntaxError{Message: "unexpected token `newline'"}
+		}
+		fileNames, err := ExpandWord(words[i+2], expander)
+		if err != nil {
+			return nil, err
+		}
+		if len(fileNames) != 1 {
+			return nil, fmt.Errorf("%s: ambiguous redirect", words[i+2])
+		}
+		fileName := fileNames[0]
+		i += 2 // Skip the operator and the filename
 
-				i++ // Skip the next word as it is the filename
-			} else {
-				fmt.Fprintln(os.Stderr, "Error: '>>' requires a filename")
+		n, _ := strconv.Atoi(fd)
+		var file *os.File
+		switch {
+		case operator == ">&" && fd == "1" && !isNumber(fileName) && fileName != "-":
+			// >&file sends both the output and the errors to file
+			if file, err = openOutput(fileName, ">", expander.Option("noclobber")); err != nil {
+				return nil, err
+			}
+			errorStream, descriptors[2] = file, file
+		case operator == ">&" || operator == "<&":
+			if file, err = duplicate(fileName, n, current); err != nil {
+				return nil, err
+			}
+		case operator == "<":
+			if file, err = os.Open(fileName); err != nil {
+				return nil, redirectionError(fileName, err)
+			}
+		default:
+			if file, err = openOutput(fileName, operator, expander.Option("noclobber")); err != nil {
+				return nil, err
 			}
 		}
-	}
-	for i := 1; i < len(words); i++ {
-		if !notIncludeInArgs[i] {
-			args = append(args, words[i])
+		switch n {
+		case 0:
+			inputStream = file
+		case 1:
+			outputStream = file
+		case 2:
+			errorStream = file
 		}
+		descriptors[n] = file
+	}
+
+	if len(fields) == 0 && len(assignments) == 0 {
+		return nil, nil
+	}
+	if len(fields) == 0 {
+		fields = []string{""} // only assignments, which stay in the shell
 	}
 
+	if inputStream == nil {
+		inputStream = curInputStream // Default input stream
+	}
 	if outputStream == nil {
 		outputStream = curOutputStream // Default output stream
 	}
 	if errorStream == nil {
-		errorStream = os.Stderr // Default error stream
+		errorStream = curErrorStream // Default error stream
 	}
 
-	return &types.Command{Name: commandName, Args: args, InputStream: curInputStream, OutputStream: outputStream, ErrorStream: errorStream}
+	// The first word is the command name, the rest are arguments
+	return &types.Command{Name: fields[0], Args: fields[1:], Assignments: assignments, Declarations: declarations,
+		InputStream: inputStream, OutputStream: outputStream, ErrorStream: errorStream, Descriptors: descriptors}, nil
+}
+
+// onlyAssignments reports whether every word of a command that is not part of a
+// redirection is an assignment, which leaves the command without a name.
+func onlyAssignments(words []string) bool {
+	for i := 0; i < len(words); i++ {
+		if i+1 < len(words) && isRedirectOperator(words[i+1]) {
+			i += 2 // Skip the operator and the filename
+			continue
+		}
+		if _, _, _, _, ok := splitAssignment(words[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// duplicate returns the file the redirection n>&word or n<&word leaves descriptor n with:
+// the one descriptor word refers to, or nil for the word "-", which closes n.
+func duplicate(word string, n int, current func(int) (*os.File, bool)) (*os.File, error) {
+	if word == "-" {
+		if n <= 2 {
+			return nil, fmt.Errorf("%d: cannot close a standard stream", n)
+		}
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(word)
+	if err != nil {
+		return nil, fmt.Errorf("%s: ambiguous redirect", word)
+	}
+	file, ok := current(fd)
+	if !ok {
+		return nil, fmt.Errorf("%d: Bad file descriptor", fd)
+	}
+	return file, nil
+}
+
+// openOutput opens the file of an output redirection. With noclobber, ">" refuses to
+// truncate an existing regular file, which ">|" still does.
+func openOutput(fileName string, operator string, noclobber bool) (*os.File, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if operator == ">>" {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	} else if operator == ">" && noclobber {
+		if info, err := os.Stat(fileName); err == nil && info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: cannot overwrite existing file", fileName)
+		} else if err != nil {
+			flags |= os.O_EXCL // fail rather than overwrite a file created meanwhile
+		} else {
+			flags &^= os.O_TRUNC // devices like /dev/null may still be written to
+		}
+	}
+
+	file, err := os.OpenFile(fileName, flags, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("%s: cannot overwrite existing file", fileName)
+		}
+		return nil, redirectionError(fileName, err)
+	}
+	return file, nil
 }
 
 // Splits input by Pipe characters
@@ -237,3 +420,8 @@ func GetCommands(input string) []string {
 	commandStrings := splitByPipes(input)
 	return commandStrings
 }
+
+// Splits input into the pipelines of a command list
+func GetList(input string) ([]ListItem, error) {
+	return splitList(input)
+}
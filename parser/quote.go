@@ -0,0 +1,17 @@
+package parser
+
+import "strings"
+
+// Quote returns s as a single shell word that expands back to s. Words without special
+// characters are returned as they are, others are put in single quotes.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) && strings.IndexByte("@%+=:,./-", s[i]) == -1 {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		}
+	}
+	return s
+}
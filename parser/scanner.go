@@ -0,0 +1,155 @@
+package parser
+
+import "strings"
+
+// quoteScanner tracks quoting and nesting while walking over raw shell input one
+// byte at a time, so that splitters only act on characters that really are unquoted.
+type quoteScanner struct {
+	inSingleQuotes bool
+	escaped        bool
+	afterDollar    bool   // previous byte was an unquoted, unescaped '$'
+	afterBoundary  bool   // previous byte was an unquoted blank or operator, so a word starts here
+	nesting        []byte // open '"', '`', '(', '{' and '[' (for [[ ]]) contexts, innermost last
+}
+
+// next advances the scanner over input[i] and reports whether that byte sits at the
+// top level, i.e. outside any quotes, escapes and nested constructs like $(...).
+func (q *quoteScanner) next(input string, i int) bool {
+	c := input[i]
+	topLevel := q.balanced()
+	afterDollar := q.afterDollar
+	wordStart := i == 0 || q.afterBoundary
+	q.afterDollar = false
+	q.afterBoundary = false
+
+	switch {
+	case q.escaped:
+		q.escaped = false
+	case q.inSingleQuotes:
+		if c == '\'' {
+			q.inSingleQuotes = false
+		}
+	case c == '\\':
+		q.escaped = true
+	case c == '$':
+		q.afterDollar = true
+	default:
+		if context := q.context(); context == 0 || context == '(' || context == '[' {
+			q.afterBoundary = strings.IndexByte(" \t\n;&|()<>", c) >= 0
+		}
+		q.step(input, i, afterDollar, wordStart)
+	}
+	return topLevel
+}
+
+// isConditionalBracket reports whether input[i:] starts with the word "[[" or "]]".
+func isConditionalBracket(input string, i int, bracket byte) bool {
+	return i+1 < len(input) && input[i] == bracket && input[i+1] == bracket &&
+		(i+2 == len(input) || strings.IndexByte(" \t\n;&|)", input[i+2]) >= 0)
+}
+
+// commentStarts reports whether input[i] is an unquoted '#' at the start of a word,
+// which comments out the rest of the line.
+func (q *quoteScanner) commentStarts(input string, i int) bool {
+	if input[i] != '#' || q.inSingleQuotes || q.escaped {
+		return false
+	}
+	if context := q.context(); context != 0 && context != '(' && context != '[' {
+		return false
+	}
+	return i == 0 || q.afterBoundary
+}
+
+// context returns the innermost open construct, or 0 at the top level.
+func (q *quoteScanner) context() byte {
+	if len(q.nesting) == 0 {
+		return 0
+	}
+	return q.nesting[len(q.nesting)-1]
+}
+
+// step updates the nesting stack for an unescaped byte outside single quotes.
+func (q *quoteScanner) step(input string, i int, afterDollar bool, wordStart bool) {
+	c := input[i]
+	context := q.context()
+	switch context {
+	case '"':
+		switch {
+		case c == '"':
+			q.pop()
+		case c == '`':
+			q.push('`')
+		case c == '(' && afterDollar:
+			q.push('(')
+		case c == '{' && afterDollar:
+			q.push('{')
+		}
+	case '`':
+		if c == '`' {
+			q.pop()
+		}
+	default: // top level, or inside $(...), <(...), ${...} or [[ ]]
+		switch {
+		case wordStart && context != '{' && isConditionalBracket(input, i, '['):
+			q.push('[') // a [[ ]] expression is kept together as one word
+		case wordStart && context == '[' && isConditionalBracket(input, i, ']'):
+			q.pop()
+		case c == '\'':
+			q.inSingleQuotes = true
+		case c == '"' || c == '`' || c == '(':
+			q.push(c)
+		case c == '{' && afterDollar:
+			q.push('{')
+		case c == ')' && context == '(':
+			q.pop()
+		case c == '}' && context == '{':
+			q.pop()
+		}
+	}
+}
+
+func (q *quoteScanner) push(c byte) {
+	q.nesting = append(q.nesting, c)
+}
+
+func (q *quoteScanner) pop() {
+	q.nesting = q.nesting[:len(q.nesting)-1]
+}
+
+// balanced reports whether every quote, escape and nested construct seen so far is closed.
+func (q *quoteScanner) balanced() bool {
+	return !q.inSingleQuotes && !q.escaped && len(q.nesting) == 0
+}
+
+// StripComments removes comments from input: an unquoted '#' at the start of a word up to,
+// but not including, the end of its line. A '#' inside a word, like in $# or ${#var}, is kept.
+func StripComments(input string) string {
+	var (
+		result  string
+		scanner quoteScanner
+	)
+	for i := 0; i < len(input); i++ {
+		if scanner.commentStarts(input, i) {
+			end := strings.IndexByte(input[i:], '\n')
+			if end == -1 {
+				break
+			}
+			i += end
+		}
+		scanner.next(input, i)
+		result += input[i : i+1]
+	}
+	return result
+}
+
+// matchingParen returns the index of the ')' closing the '(' at input[open], or -1.
+func matchingParen(input string, open int) int {
+	var scanner quoteScanner
+	for i := open; i < len(input); i++ {
+		scanner.next(input, i)
+		if scanner.balanced() {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,123 @@
+package pattern
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// Glob returns the paths matching pattern in sorted order, or nil if there are none.
+// Each '/' separated part of the pattern is matched against the names in one directory
+// level, and a name starting with '.' is only matched by a part that starts with '.',
+// unless options.DotGlob is set.
+func Glob(pattern string, options Options) []string {
+	segments := strings.Split(pattern, "/")
+	last := segments[len(segments)-1]
+
+	prefixes := []string{""} // directories matched so far, each ending in '/' unless empty
+	for _, segment := range segments[:len(segments)-1] {
+		var next []string
+		for _, prefix := range prefixes {
+			if options.GlobStar && segment == "**" {
+				next = append(next, prefix) // "**/" also matches no directory at all
+				for _, directory := range walk(prefix, options, false) {
+					next = append(next, directory+"/")
+				}
+				continue
+			}
+			for _, path := range expandSegment(prefix, segment, options) {
+				next = append(next, path+"/")
+			}
+		}
+		prefixes = next
+	}
+
+	var paths []string
+	for _, prefix := range prefixes {
+		if options.GlobStar && last == "**" {
+			paths = append(paths, walk(prefix, options, true)...)
+		} else {
+			paths = append(paths, expandSegment(prefix, last, options)...)
+		}
+	}
+
+	// Literal parts were joined without looking at the file system
+	var result []string
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			result = append(result, path)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// expandSegment returns prefix joined with each name in the directory prefix that matches
+// segment. A segment without special characters is joined as it is.
+func expandSegment(prefix string, segment string, options Options) []string {
+	if !HasMeta(segment, options) {
+		return []string{prefix + unescape(segment)}
+	}
+
+	entries, err := os.ReadDir(directoryOf(prefix))
+	if err != nil {
+		return nil
+	}
+
+	match := compile(segment, options)
+	matchHidden := options.DotGlob || strings.HasPrefix(segment, ".") || strings.HasPrefix(segment, `\.`)
+	var result []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !matchHidden {
+			continue
+		}
+		if match(name) {
+			result = append(result, prefix+name)
+		}
+	}
+	return result
+}
+
+// walk returns the directories below prefix, and the files too if files is set, for "**".
+// Symbolic links to directories are not followed.
+func walk(prefix string, options Options, files bool) []string {
+	entries, err := os.ReadDir(directoryOf(prefix))
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !options.DotGlob {
+			continue
+		}
+		if entry.IsDir() {
+			result = append(result, prefix+name)
+			result = append(result, walk(prefix+name+"/", options, files)...)
+		} else if files {
+			result = append(result, prefix+name)
+		}
+	}
+	return result
+}
+
+func directoryOf(prefix string) string {
+	if prefix == "" {
+		return "."
+	}
+	return prefix
+}
+
+// unescape removes the backslashes from a pattern without special characters.
+func unescape(pattern string) string {
+	var result strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		result.WriteByte(pattern[i])
+	}
+	return result.String()
+}
@@ -0,0 +1,257 @@
+package pattern
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options change how patterns are matched.
+type Options struct {
+	ExtGlob  bool // ?(list), *(list), +(list), @(list) and !(list) match '|' separated patterns
+	NoCase   bool // Letters match regardless of their case
+	DotGlob  bool // In Glob, wildcards also match a leading '.' of a file name
+	GlobStar bool // In Glob, "**" matches any number of directories
+}
+
+// Match reports whether s matches the shell pattern in its entirety. Besides
+// literal text a pattern may contain *, ? and [...] bracket expressions, and a
+// backslash makes the following character literal.
+func Match(pattern string, s string, options Options) bool {
+	return compile(pattern, options)(s)
+}
+
+// compile turns a pattern into a function matching strings against it.
+func compile(pattern string, options Options) func(string) bool {
+	if options.ExtGlob {
+		if start, end := findNegation(pattern); start != -1 {
+			return compileNegation(pattern, start, end, options)
+		}
+	}
+
+	flags := `(?s)`
+	if options.NoCase {
+		flags = `(?is)`
+	}
+	re, err := regexp.Compile(flags + "^" + translate(pattern, options) + "$")
+	if err != nil {
+		return func(s string) bool { return pattern == s }
+	}
+	return re.MatchString
+}
+
+// compileNegation matches a pattern with a !(list) group, which regexp cannot express.
+// A string matches if it splits into a part matching what comes before the group, a part
+// matching none of the list and a part matching what comes after it.
+func compileNegation(pattern string, start int, end int, options Options) func(string) bool {
+	matchPrefix := compile(pattern[:start], options)
+	matchList := compile("@("+pattern[start+2:end]+")", options)
+	matchSuffix := compile(pattern[end+1:], options)
+	return func(s string) bool {
+		for i := 0; i <= len(s); i++ {
+			if !matchPrefix(s[:i]) {
+				continue
+			}
+			for j := i; j <= len(s); j++ {
+				if !matchList(s[i:j]) && matchSuffix(s[j:]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// HasMeta reports whether pattern contains any unescaped special characters.
+func HasMeta(pattern string, options Options) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		case '+', '@', '!':
+			if options.ExtGlob && i+1 < len(pattern) && pattern[i+1] == '(' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Escape makes every character of s match literally.
+func Escape(s string) string {
+	var result strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\+@!()|`, r) {
+			result.WriteByte('\\')
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
+
+// translate translates a shell pattern into a regular expression.
+func translate(pattern string, options Options) string {
+	var result strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if options.ExtGlob && strings.IndexByte("?*+@!", c) >= 0 && i+1 < len(pattern) && pattern[i+1] == '(' {
+			if end := groupEnd(pattern, i+1); end != -1 {
+				result.WriteString(translateGroup(c, pattern[i+2:end], options))
+				i = end
+				continue
+			}
+		}
+
+		switch c {
+		case '*':
+			result.WriteString(`.*`)
+		case '?':
+			result.WriteString(`.`)
+		case '[':
+			if class, end := bracketExpression(pattern, i); end != -1 {
+				result.WriteString(class)
+				i = end
+			} else {
+				result.WriteString(`\[`) // unterminated bracket matches itself
+			}
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			fallthrough
+		default:
+			_, size := utf8.DecodeRuneInString(pattern[i:])
+			result.WriteString(regexp.QuoteMeta(pattern[i : i+size]))
+			i += size - 1
+		}
+	}
+	return result.String()
+}
+
+// translateGroup translates the list of an extended glob group like +(a|b*).
+func translateGroup(kind byte, list string, options Options) string {
+	var alternatives []string
+	for _, alternative := range splitAlternatives(list) {
+		alternatives = append(alternatives, translate(alternative, options))
+	}
+	group := `(?:` + strings.Join(alternatives, "|") + `)`
+	switch kind {
+	case '?':
+		return group + `?`
+	case '*':
+		return group + `*`
+	case '+':
+		return group + `+`
+	case '!':
+		return `.*` // only a negation outside other groups is exact, see compileNegation
+	default: // '@'
+		return group
+	}
+}
+
+// findNegation returns the positions of the '!' and the closing ')' of the first !(list)
+// group that is not inside another group, or -1 if there is none.
+func findNegation(pattern string) (int, int) {
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case pattern[i] == '\\':
+			i++
+		case pattern[i] == '(':
+			if end := groupEnd(pattern, i); end != -1 {
+				i = end
+			}
+		case pattern[i] == '!' && i+1 < len(pattern) && pattern[i+1] == '(':
+			if end := groupEnd(pattern, i+1); end != -1 {
+				return i, end
+			}
+		}
+	}
+	return -1, -1
+}
+
+// groupEnd returns the index of the ')' closing the '(' at pattern[open], or -1.
+func groupEnd(pattern string, open int) int {
+	depth := 0
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits the list of a group at the '|' characters outside nested groups.
+func splitAlternatives(list string) []string {
+	var (
+		result []string
+		start  int
+		depth  int
+	)
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				result = append(result, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, list[start:])
+}
+
+// bracketExpression translates the bracket expression starting at pattern[start] into a
+// regular expression character class. It returns the index of the closing ']', or -1 if
+// there is none.
+func bracketExpression(pattern string, start int) (string, int) {
+	var class strings.Builder
+	class.WriteByte('[')
+
+	i := start + 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		class.WriteByte('^')
+		i++
+	}
+	for first := true; i < len(pattern); i, first = i+1, false {
+		c := pattern[i]
+		switch {
+		case c == ']' && !first:
+			class.WriteByte(']')
+			return class.String(), i
+		case c == '[' && i+1 < len(pattern) && pattern[i+1] == ':':
+			// Character classes like [:alpha:] are understood by regexp as well
+			end := strings.Index(pattern[i+2:], ":]")
+			if end == -1 {
+				class.WriteString(`\[`)
+				continue
+			}
+			class.WriteString(pattern[i : i+2+end+2])
+			i += 2 + end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			fallthrough
+		case c != '-':
+			_, size := utf8.DecodeRuneInString(pattern[i:])
+			class.WriteString(regexp.QuoteMeta(pattern[i : i+size]))
+			i += size - 1
+		default:
+			class.WriteByte('-')
+		}
+	}
+	return "", -1
+}
@@ -0,0 +1,80 @@
+package pattern
+
+import "testing"
+
+func TestMatchExtGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"?(a|b)c", "c", true},
+		{"?(a|b)c", "ac", true},
+		{"?(a|b)c", "abc", false},
+		{"*(ab)", "", true},
+		{"*(ab)", "abab", true},
+		{"*(ab)", "aba", false},
+		{"+(a|bc)d", "d", false},
+		{"+(a|bc)d", "abcad", true},
+		{"@(x|y)z", "xz", true},
+		{"@(x|y)z", "xyz", false},
+		{"!(foo)", "foo", false},
+		{"!(foo)", "bar", true},
+		{"!(foo)", "foobar", true},
+		{"!(foo)", "", true},
+		{"*.!(txt)", "a.txt", false},
+		{"*.!(txt)", "a.go", true},
+		{"a!(b)c", "ac", true},
+		{"a!(b)c", "abc", false},
+		{"a!(b)c", "abbc", true},
+		{"@(a|*.go)", "x.go", true},
+		{"+([0-9])", "123", true},
+		{"+([0-9])", "12a", false},
+		{"?(a|@(b|c))d", "cd", true},
+		{`\*(a)`, "*(a)", true},
+	}
+	for _, test := range tests {
+		if got := Match(test.pattern, test.s, Options{ExtGlob: true}); got != test.want {
+			t.Errorf("Match(%q, %q) with extglob = %v; want %v", test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestMatchWithoutExtGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"?(a)", "x(a)", true},
+		{"?(a)", "a", false},
+		{"+(a)", "+(a)", true},
+		{"!(a)", "b", false},
+		{"a|b", "a|b", true},
+	}
+	for _, test := range tests {
+		if got := Match(test.pattern, test.s, Options{}); got != test.want {
+			t.Errorf("Match(%q, %q) = %v; want %v", test.pattern, test.s, got, test.want)
+		}
+	}
+}
+
+func TestHasMetaExtGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		extGlob bool
+		want    bool
+	}{
+		{"@(a|b)", true, true},
+		{"+(a)", true, true},
+		{"!(a)", true, true},
+		{"@(a|b)", false, false},
+		{"a+b", true, false},
+		{`\@(a)`, true, false},
+	}
+	for _, test := range tests {
+		if got := HasMeta(test.pattern, Options{ExtGlob: test.extGlob}); got != test.want {
+			t.Errorf("HasMeta(%q) with extglob %v = %v; want %v", test.pattern, test.extGlob, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// assignVariables performs the assignments written before a special builtin, which stay
+// in the shell's variables.
+func (s *Shell) assignVariables(cmd *types.Command) (int, bool) {
+	for _, assignment := range cmd.Assignments {
+		if err := s.applyAssignment(assignment); err != nil {
+			fmt.Fprintln(cmd.ErrorStream, err)
+			return 1, !s.interactive
+		}
+	}
+	return 0, false
+}
+
+// Assign performs an assignment of a command that has no name while the command is
+// expanded, so that each assignment sees the values of those before it.
+func (s *Shell) Assign(assignment types.Assignment) error {
+	return s.applyAssignment(assignment)
+}
+
+// runCommand runs a command with the assignments written before its name. They stay in
+// the shell for special builtins, last while other builtins run, and only go into the
+// environment of external commands.
+func (s *Shell) runCommand(cmd *types.Command) (int, bool) {
+	switch {
+	case len(cmd.Assignments) == 0:
+		return s.processCommand(cmd)
+	case slices.Contains(specialBuiltIns, cmd.Name):
+		if status, exit := s.assignVariables(cmd); status != 0 {
+			return status, exit
+		}
+		return s.processCommand(cmd)
+	case !slices.Contains(s.builtIns, cmd.Name):
+		for _, assignment := range cmd.Assignments {
+			if err := s.checkAssignable(assignment.Name); err != nil {
+				fmt.Fprintln(cmd.ErrorStream, err)
+				return 1, false
+			}
+		}
+		return s.processCommand(cmd) // see commandEnvironment
+	}
+
+	for _, assignment := range cmd.Assignments {
+		saved := s.saveVariable(assignment.Name)
+		defer s.restoreVariable(assignment.Name, saved)
+		if err := s.declare(assignment.Name, &assignment, exported, 0); err != nil {
+			fmt.Fprintf(cmd.ErrorStream, "%v\n", err)
+			return 1, false
+		}
+	}
+	return s.processCommand(cmd)
+}
+
+// commandEnvironment returns the environment of an external command: the exported
+// variables together with the assignments written before the command's name.
+func (s *Shell) commandEnvironment(cmd *types.Command) []string {
+	environment := s.environment()
+	for _, assignment := range cmd.Assignments {
+		if assignment.Compound || assignment.Index != "" {
+			continue // Arrays cannot be exported
+		}
+		value := assignment.Value
+		if assignment.Append {
+			value, _ = s.Parameter(assignment.Name)
+			value += assignment.Value
+		}
+		environment = append(withoutVariable(environment, assignment.Name), assignment.Name+"="+value)
+	}
+	return environment
+}
+
+// applyAssignment performs one assignment to a variable, an element of an array or a
+// whole array.
+func (s *Shell) applyAssignment(a types.Assignment) error {
+	s.variablesLock.Lock()
+	name := s.resolve(a.Name)
+	s.variablesLock.Unlock()
+
+	switch {
+	case a.Compound:
+		return s.assignArray(name, a)
+	case a.Index != "":
+		key, err := s.elementKey(name, a.Index)
+		if err != nil {
+			return err
+		}
+		value := a.Value
+		if a.Append {
+			old, _ := s.Element(name, key)
+			value = s.appendedValue(name, old, value)
+		}
+		return s.setElement(name, key, value)
+	case a.Append:
+		old, _ := s.Element(name, "0")
+		return s.assign(name, s.appendedValue(name, old, a.Value))
+	}
+	return s.assign(name, a.Value)
+}
+
+// appendedValue returns the value "+=" assigns: the sum for integer variables, the
+// concatenation otherwise.
+func (s *Shell) appendedValue(name string, old string, value string) string {
+	if s.hasAttribute(name, integer) {
+		if old == "" {
+			old = "0"
+		}
+		return old + "+(" + value + ")"
+	}
+	return old + value
+}
+
+// assignArray assigns the elements of a compound assignment, name=(element...), to an
+// array. Elements without an index follow the previous one, starting at 0, or after the
+// last element when appending.
+func (s *Shell) assignArray(name string, a types.Assignment) error {
+	if err := s.checkAssignable(name); err != nil {
+		return err
+	}
+	assoc := s.hasAttribute(name, assocArray)
+
+	s.variablesLock.Lock()
+	next := 0
+	if a.Append {
+		next = s.nextIndex(name)
+	}
+	v := s.variable(name)
+	if !v.isArray() || !a.Append {
+		old := v.value
+		if !assoc {
+			v.attributes |= indexedArray
+		}
+		v.elements = make(map[string]string)
+		if a.Append && v.set {
+			v.elements["0"] = old
+		}
+	}
+	v.set = true
+	s.variablesLock.Unlock()
+
+	for i, element := range a.Elements {
+		index := a.Indices[i]
+		var key string
+		switch {
+		case assoc && index == "":
+			return fmt.Errorf("%s: %s: must use subscript when assigning associative array", name, element)
+		case index == "":
+			key = strconv.Itoa(next)
+		default:
+			var err error
+			if key, err = s.elementKey(name, index); err != nil {
+				return err
+			}
+		}
+		if !assoc {
+			next, _ = strconv.Atoi(key)
+			next++
+		}
+		if err := s.setElement(name, key, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveVariable returns a copy of a variable, or nil if it does not exist, to restore it
+// after a temporary assignment.
+func (s *Shell) saveVariable(name string) *variable {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	v := s.variables[s.resolve(name)]
+	if v == nil {
+		return nil
+	}
+	saved := *v
+	saved.elements = maps.Clone(v.elements)
+	return &saved
+}
+
+// restoreVariable puts back a variable saved with saveVariable.
+func (s *Shell) restoreVariable(name string, saved *variable) {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	name = s.resolve(name)
+	if saved == nil {
+		delete(s.variables, name)
+	} else {
+		s.variables[name] = saved
+	}
+}
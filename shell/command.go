@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/fsutil"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// defaultPath is the PATH "command -p" searches, which finds the standard utilities.
+const defaultPath = "/bin:/usr/bin"
+
+// handleCommand handles the "command" command, which runs a builtin or external command
+// without the special properties of special builtins, or describes commands:
+//
+//	command [-p] name [argument...]
+//	command [-p] -v|-V name...
+//
+// -p searches defaultPath instead of PATH. -v prints the name of a builtin or the path of
+// an external command, -V says which of the two it is.
+func (s *Shell) handleCommand(command *types.Command) (int, bool) {
+	defaultSearch, describe, verbose := false, false, false
+	args := command.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'p':
+				defaultSearch = true
+			case 'v':
+				describe = true
+			case 'V':
+				describe, verbose = true, true
+			default:
+				fmt.Fprintf(command.ErrorStream, "command: -%c: invalid option\n", flag)
+				fmt.Fprintln(command.ErrorStream, "command: usage: command [-pVv] command [arg ...]")
+				return 2, false
+			}
+		}
+	}
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	finder := s.finder()
+	if defaultSearch {
+		finder = fsutil.NewFinder(strings.Split(defaultPath, ":"))
+	}
+	if describe {
+		return s.describeCommands(command, args, finder, verbose), false
+	}
+
+	run := *command
+	run.Name, run.Args, run.Declarations = args[0], args[1:], nil
+	if !defaultSearch || slices.Contains(s.builtIns, run.Name) {
+		// PATH is searched through the hash table as for other commands
+		return s.processCommand(&run)
+	}
+	path, found := finder.FindExecutablePath(run.Name)
+	if !found {
+		fmt.Fprintf(command.ErrorStream, "%s: command not found\n", run.Name)
+		return 127, false
+	}
+	return s.runExecutable(&run, path, s.commandEnvironment(&run)), false
+}
+
+// describeCommands prints what each name runs as for "command -v" or "command -V".
+// Returns 1 if none of them is found.
+func (s *Shell) describeCommands(command *types.Command, names []string, finder *fsutil.Finder, verbose bool) int {
+	status := 1
+	for _, name := range names {
+		isBuiltin := slices.Contains(s.builtIns, name)
+		path, found := finder.FindExecutablePath(name)
+		if isBuiltin || found {
+			status = 0
+		}
+		switch {
+		case isBuiltin && verbose:
+			fmt.Fprintf(command.OutputStream, "%s is a shell builtin\n", name)
+		case isBuiltin:
+			fmt.Fprintln(command.OutputStream, name)
+		case found && verbose:
+			fmt.Fprintf(command.OutputStream, "%s is %s\n", name, path)
+		case found:
+			fmt.Fprintln(command.OutputStream, path)
+		default:
+			if verbose {
+				fmt.Fprintf(command.ErrorStream, "command: %s: not found\n", name)
+			}
+		}
+	}
+	return status
+}
+
+// handleBuiltin handles the "builtin" command, which runs a builtin even if an external
+// command has the same name:
+//
+//	builtin name [argument...]
+func (s *Shell) handleBuiltin(command *types.Command) (int, bool) {
+	if len(command.Args) == 0 {
+		return 0, false
+	}
+	if !slices.Contains(s.builtIns, command.Args[0]) {
+		fmt.Fprintf(command.ErrorStream, "builtin: %s: not a shell builtin\n", command.Args[0])
+		return 1, false
+	}
+	run := *command
+	run.Name, run.Args, run.Declarations = command.Args[0], command.Args[1:], nil
+	return s.processCommand(&run)
+}
@@ -0,0 +1,33 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// commandSubstitution runs list in a subshell for a $(list) or `list` word of a command
+// with inputStream as its standard input and errorStream as its standard error, and returns the output of list without trailing
+// newlines, and its status.
+func (s *Shell) commandSubstitution(list string, inputStream *os.File, errorStream *os.File) (string, int) {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating pipe: %v\n", err)
+		return "", 1
+	}
+	defer pipeReader.Close()
+
+	subshell, err := s.startSubshell(list, inputStream, pipeWriter, errorStream)
+	pipeWriter.Close() // Only the subshell writes to the pipe, so it ends with the subshell
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting subshell: %v\n", err)
+		return "", 1
+	}
+
+	output, err := io.ReadAll(pipeReader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading command output: %v\n", err)
+	}
+	return strings.TrimRight(string(output), "\n"), waitSubshell(subshell)
+}
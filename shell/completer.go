@@ -3,16 +3,35 @@ package shell
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/codecrafters-io/shell-starter-go/trie"
 )
 
 type TabCompleter struct {
-	trie                           *trie.TrieNode // Assuming TrieNode is defined in trie package
+	trie                           *trie.TrieNode        // Assuming TrieNode is defined in trie package
+	commands                       func() *trie.TrieNode // Returns the current trie of command names
 	tabPressedAfterMultipleResults bool
 	lastEnteredLine                string
 }
 
+// commandTrie returns the trie of command names to complete: the builtins and the
+// executables in PATH. It is only rebuilt when PATH changes or files are added to or
+// removed from its directories.
+func (s *Shell) commandTrie() *trie.TrieNode {
+	finder := s.finder()
+	if s.completionTrie != nil && finder == s.completionFinder && !finder.ChangedSince(s.completionTime) {
+		return s.completionTrie
+	}
+
+	s.completionFinder, s.completionTime = finder, time.Now()
+	allCommands := make([]string, 0)
+	allCommands = append(allCommands, s.builtIns...)
+	allCommands = append(allCommands, finder.GetExecutables()...) // Get executables from PATH
+	s.completionTrie = trie.NewTrieNode(allCommands)
+	return s.completionTrie
+}
+
 func ringBell() {
 	// Ring the bell (alert) to indicate no completion found
 	fmt.Print("\x07")
@@ -34,6 +53,7 @@ func getUniqueStrings(strs []string) []string {
 }
 
 func (t *TabCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	t.trie = t.commands() // PATH may have changed since the last completion
 	candidates := t.trie.GetAllMatching(string(line))
 
 	// retain only unique
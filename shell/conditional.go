@@ -0,0 +1,23 @@
+package shell
+
+import (
+	builtin "github.com/codecrafters-io/shell-starter-go/builtins"
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// handleConditional handles a "[[ ]]" expression. Its operands are expanded only when
+// they are evaluated, so the right side of && and || may never be expanded.
+func (s *Shell) handleConditional(cmd *types.Command) int {
+	expander := &commandExpander{Shell: s, processSubstitutions: newProcessSubstitutions(s, cmd.InputStream, cmd.ErrorStream)}
+	defer expander.wait()
+
+	return builtin.HandleConditional(cmd, builtin.ConditionalContext{
+		Expand: func(word string, quote func(string) string) (string, error) {
+			return parser.ExpandString(word, expander, quote)
+		},
+		SetMatch: func(groups []string) {
+			s.setArray("BASH_REMATCH", groups)
+		},
+	})
+}
@@ -0,0 +1,354 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// declareOptions are the parsed options of declare, export and readonly.
+type declareOptions struct {
+	add       attribute // Attributes turned on with -<letter>
+	remove    attribute // Attributes turned off with +<letter>
+	print     bool      // -p: print the variables instead of changing them
+	functions bool      // -f or -F: act on functions, which the shell does not have
+}
+
+// parseDeclareOptions parses the options of the named builtin, which accepts the attribute
+// letters in allowed. Returns the remaining arguments.
+func parseDeclareOptions(command *types.Command, allowed string) (declareOptions, []string, error) {
+	var options declareOptions
+	args := command.Args
+	for len(args) > 0 && len(args[0]) > 1 && (args[0][0] == '-' || args[0][0] == '+') {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for _, letter := range []byte(arg[1:]) {
+			switch {
+			case letter == 'p' && arg[0] == '-':
+				options.print = true
+			case letter == 'f' || letter == 'F':
+				options.functions = true
+			case letter == 'g' && command.Name != "export" && command.Name != "readonly":
+				// Variables are always global, as there are no functions
+			case strings.IndexByte(allowed, letter) >= 0:
+				attribute := letterAttribute(letter)
+				if arg[0] == '-' {
+					options.add |= attribute
+				} else {
+					options.remove |= attribute
+				}
+			default:
+				return options, nil, fmt.Errorf("%c%c: invalid option", arg[0], letter)
+			}
+		}
+	}
+	return options, args, nil
+}
+
+func letterAttribute(letter byte) attribute {
+	for _, attribute := range attributeLetters {
+		if attribute.letter == letter {
+			return attribute.attribute
+		}
+	}
+	return 0
+}
+
+// handleDeclare handles the "declare" and "typeset" commands, which set the attributes and
+// values of variables:
+//
+//	declare [-aAilnrux] [-p] [name[=value]...]
+func (s *Shell) handleDeclare(command *types.Command) int {
+	options, args, err := parseDeclareOptions(command, "aAilnrux")
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "%s: %v\n", command.Name, err)
+		fmt.Fprintf(command.ErrorStream, "%s: usage: %s [-aAfFgilnrux] [-p] [name[=value] ...]\n", command.Name, command.Name)
+		return 2
+	}
+	switch {
+	case options.functions:
+		return min(len(args), 1) // There are no functions to print
+	case len(args) == 0 && !options.print && options.add|options.remove == 0:
+		s.printVariables(command.OutputStream)
+		return 0
+	case len(args) == 0:
+		s.printDeclarations(command, options.add|options.remove)
+		return 0
+	case options.print:
+		return s.printNamedDeclarations(command, args)
+	}
+	return s.declareAll(command, args, options)
+}
+
+// handleExport handles the "export" command, which passes variables to child processes:
+//
+//	export [-n] [-p] [name[=value]...]
+func (s *Shell) handleExport(command *types.Command) int {
+	options, args, err := parseDeclareOptions(command, "n")
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "export: %v\n", err)
+		fmt.Fprintln(command.ErrorStream, "export: usage: export [-fn] [name[=value] ...] or export -p")
+		return 2
+	}
+	if options.functions {
+		return min(len(args), 1)
+	}
+	if len(args) == 0 {
+		s.printDeclarations(command, exported)
+		return 0
+	}
+	// -n takes the export attribute away instead of being the nameref attribute
+	if options.add&nameref != 0 {
+		options = declareOptions{remove: exported}
+	} else {
+		options = declareOptions{add: exported}
+	}
+	return s.declareAll(command, args, options)
+}
+
+// handleReadonly handles the "readonly" command, which stops variables from changing:
+//
+//	readonly [-aA] [-p] [name[=value]...]
+func (s *Shell) handleReadonly(command *types.Command) int {
+	options, args, err := parseDeclareOptions(command, "aA")
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "readonly: %v\n", err)
+		fmt.Fprintln(command.ErrorStream, "readonly: usage: readonly [-aAf] [name[=value] ...] or readonly -p")
+		return 2
+	}
+	if options.functions {
+		return min(len(args), 1)
+	}
+	if len(args) == 0 {
+		s.printDeclarations(command, readOnly)
+		return 0
+	}
+	options.add |= readOnly
+	return s.declareAll(command, args, options)
+}
+
+// handleUnset handles the "unset" command:
+//
+//	unset [-f] [-v] [-n] name...
+func (s *Shell) handleUnset(command *types.Command) int {
+	functions, unsetNameref := false, false
+	args := command.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for _, letter := range arg[1:] {
+			switch letter {
+			case 'f':
+				functions = true
+			case 'v':
+				functions = false
+			case 'n':
+				unsetNameref = true
+			default:
+				fmt.Fprintf(command.ErrorStream, "unset: -%c: invalid option\n", letter)
+				fmt.Fprintln(command.ErrorStream, "unset: usage: unset [-f] [-v] [-n] [name ...]")
+				return 2
+			}
+		}
+	}
+
+	status := 0
+	for _, name := range args {
+		if functions {
+			continue // There are no functions to unset
+		}
+		if arrayName, index, ok := parser.SplitSubscript(name); ok && parser.IsValidName(arrayName) {
+			if err := s.unsetElement(arrayName, index); err != nil {
+				fmt.Fprintf(command.ErrorStream, "unset: %v\n", err)
+				status = 1
+			}
+			continue
+		}
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(command.ErrorStream, "unset: `%s': not a valid identifier\n", name)
+			status = 1
+			continue
+		}
+		if err := s.unsetVariable(name, unsetNameref); err != nil {
+			fmt.Fprintf(command.ErrorStream, "unset: %v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// declareAll applies the options to every name[=value] argument of declare, export or
+// readonly. Returns 1 if any of them failed.
+func (s *Shell) declareAll(command *types.Command, args []string, options declareOptions) int {
+	status := 0
+	offset := len(command.Args) - len(args)
+	for i, arg := range args {
+		name := arg
+		var assignment *types.Assignment
+		if a, ok := command.Declarations[offset+i]; ok {
+			name, assignment = a.Name, &a
+		} else if before, value, ok := strings.Cut(arg, "="); ok {
+			// Assignments that came from an expansion are not split further
+			name, assignment = before, &types.Assignment{Name: before, Value: value}
+		}
+		if !parser.IsValidName(name) {
+			fmt.Fprintf(command.ErrorStream, "%s: `%s': not a valid identifier\n", command.Name, arg)
+			status = 1
+			continue
+		}
+		if err := s.declare(name, assignment, options.add, options.remove); err != nil {
+			fmt.Fprintf(command.ErrorStream, "%s: %v\n", command.Name, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// declare changes the attributes of a variable and performs the assignment to it if it
+// is not nil.
+func (s *Shell) declare(name string, assignment *types.Assignment, add attribute, remove attribute) error {
+	s.variablesLock.Lock()
+	if (add|remove)&nameref == 0 {
+		name = s.resolve(name) // the attributes of the referenced variable change
+	}
+	v := s.variable(name)
+	switch {
+	case v.attributes&readOnly != 0 && (assignment != nil || remove&readOnly != 0):
+		s.variablesLock.Unlock()
+		return fmt.Errorf("%s: readonly variable", name)
+	case add&assocArray != 0 && v.attributes&indexedArray != 0:
+		s.variablesLock.Unlock()
+		return fmt.Errorf("%s: cannot convert indexed to associative array", name)
+	case add&indexedArray != 0 && v.attributes&assocArray != 0:
+		s.variablesLock.Unlock()
+		return fmt.Errorf("%s: cannot convert associative to indexed array", name)
+	}
+
+	if add&(indexedArray|assocArray) != 0 && !v.isArray() {
+		// A scalar becomes element 0 of the array
+		v.elements = make(map[string]string)
+		if v.set {
+			v.elements["0"] = v.value
+		}
+	}
+	if add&lowercase != 0 {
+		remove |= uppercase
+	} else if add&uppercase != 0 {
+		remove |= lowercase
+	}
+	// readonly is added last, after the value has been assigned
+	v.attributes = v.attributes&^remove | add&^readOnly
+	s.variablesLock.Unlock()
+
+	if assignment != nil {
+		if v.attributes&nameref != 0 {
+			if !parser.IsValidName(assignment.Value) {
+				return fmt.Errorf("`%s': invalid variable name for name reference", assignment.Value)
+			}
+			s.variablesLock.Lock()
+			v.value, v.set = assignment.Value, true
+			s.variablesLock.Unlock()
+		} else if err := s.applyAssignment(*assignment); err != nil {
+			return err
+		}
+	}
+
+	s.variablesLock.Lock()
+	v.attributes |= add & readOnly
+	s.variablesLock.Unlock()
+	return nil
+}
+
+// printDeclarations prints the variables that have all the given attributes as declare
+// commands.
+func (s *Shell) printDeclarations(command *types.Command, attributes attribute) {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	for _, name := range s.variableNames() {
+		if v := s.variables[name]; v.attributes&attributes == attributes {
+			fmt.Fprintln(command.OutputStream, declaration(name, v))
+		}
+	}
+}
+
+// printNamedDeclarations prints the named variables as declare commands. Returns 1 if
+// any of them does not exist.
+func (s *Shell) printNamedDeclarations(command *types.Command, names []string) int {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	status := 0
+	for _, name := range names {
+		if v, ok := s.variables[name]; ok {
+			fmt.Fprintln(command.OutputStream, declaration(name, v))
+		} else {
+			fmt.Fprintf(command.ErrorStream, "%s: %s: not found\n", command.Name, name)
+			status = 1
+		}
+	}
+	return status
+}
+
+// handleEnv handles the "env" command, which prints the environment or runs a command in
+// a changed one:
+//
+//	env [-i] [-u name]... [name=value]... [command [argument...]]
+func (s *Shell) handleEnv(command *types.Command) int {
+	environment := s.environment()
+	args := command.Args
+options:
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+		switch {
+		case arg == "--":
+			break options
+		case arg == "-" || arg == "-i":
+			environment = nil
+		case arg == "-u" && len(args) > 0:
+			environment = withoutVariable(environment, args[0])
+			args = args[1:]
+		case strings.HasPrefix(arg, "-u") && len(arg) > 2:
+			environment = withoutVariable(environment, arg[2:])
+		default:
+			fmt.Fprintf(command.ErrorStream, "env: invalid option -- '%s'\n", strings.TrimPrefix(arg, "-"))
+			return 125
+		}
+	}
+
+	for len(args) > 0 && strings.Contains(args[0], "=") {
+		name, _, _ := strings.Cut(args[0], "=")
+		environment = append(withoutVariable(environment, name), args[0])
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		for _, entry := range environment {
+			fmt.Fprintln(command.OutputStream, entry)
+		}
+		return 0
+	}
+	run := *command
+	run.Name, run.Args = args[0], args[1:]
+	return s.executeExternalCommand(&run, environment)
+}
+
+// withoutVariable removes the variable with the given name from an environment.
+func withoutVariable(environment []string, name string) []string {
+	result := environment[:0:0]
+	for _, entry := range environment {
+		if !strings.HasPrefix(entry, name+"=") {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
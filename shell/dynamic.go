@@ -0,0 +1,96 @@
+package shell
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	mathrand "math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// addSpecialVariables sets up the variables the shell maintains itself. The dynamic ones
+// compute their value when read and lose their special meaning when unset.
+func (s *Shell) addSpecialVariables() {
+	s.random = mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+	s.secondsStart = s.startTime
+	maps.Copy(s.variables, s.dynamicVariables())
+
+	s.variables["PPID"] = &variable{value: strconv.Itoa(os.Getppid()), attributes: integer | readOnly, set: true}
+	s.variables["UID"] = &variable{value: strconv.Itoa(os.Getuid()), attributes: integer | readOnly, set: true}
+
+	// SHLVL counts how deeply shells are nested
+	level := 0
+	if v := s.variables["SHLVL"]; v != nil {
+		level, _ = strconv.Atoi(v.value)
+	}
+	s.variables["SHLVL"] = &variable{value: strconv.Itoa(level + 1), attributes: exported, set: true}
+
+	// PWD is kept if it names the working directory, which keeps the path of symbolic links
+	cwd, err := os.Getwd()
+	if v := s.variables["PWD"]; err == nil && (v == nil || !sameFile(v.value, cwd)) {
+		s.variables["PWD"] = &variable{value: cwd, attributes: exported, set: true}
+	}
+	if v := s.variables["OLDPWD"]; v == nil || !isDirectory(v.value) {
+		s.variables["OLDPWD"] = &variable{attributes: exported} // Declared without a value
+	}
+}
+
+// dynamicVariables returns the variables whose value is computed from the state of the
+// shell each time they are read.
+func (s *Shell) dynamicVariables() map[string]*variable {
+	variables := make(map[string]*variable)
+	variables["RANDOM"] = &variable{
+		attributes: integer,
+		set:        true,
+		get:        func() string { return strconv.Itoa(s.random.Intn(32768)) },
+		assigned: func(value string) { // Assigning a seed repeats the sequence
+			seed, _ := strconv.ParseInt(value, 10, 64)
+			s.random = mathrand.New(mathrand.NewSource(seed))
+		},
+	}
+	variables["SRANDOM"] = &variable{
+		attributes: integer,
+		set:        true,
+		get: func() string { // 32 random bits that cannot be seeded
+			var bits [4]byte
+			rand.Read(bits[:])
+			return strconv.FormatUint(uint64(binary.LittleEndian.Uint32(bits[:])), 10)
+		},
+	}
+	variables["SECONDS"] = &variable{
+		attributes: integer,
+		set:        true,
+		get:        func() string { return strconv.Itoa(int(time.Since(s.secondsStart).Seconds())) },
+		assigned: func(value string) { // Counting continues from the assigned number
+			seconds, _ := strconv.Atoi(value)
+			s.secondsStart = time.Now().Add(-time.Duration(seconds) * time.Second)
+		},
+	}
+	variables["EPOCHSECONDS"] = &variable{
+		set: true,
+		get: func() string { return strconv.FormatInt(time.Now().Unix(), 10) },
+	}
+	variables["EPOCHREALTIME"] = &variable{
+		set: true,
+		get: func() string {
+			now := time.Now()
+			return fmt.Sprintf("%d.%06d", now.Unix(), now.Nanosecond()/1000)
+		},
+	}
+	variables["LINENO"] = &variable{
+		attributes: integer,
+		set:        true,
+		get:        func() string { return strconv.Itoa(s.lineNumber) },
+	}
+	return variables
+}
+
+// sameFile reports whether two paths refer to the same existing file.
+func sameFile(a string, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
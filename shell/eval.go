@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// handleEval handles the "eval" command, which joins its arguments with spaces and runs
+// the result in the current shell:
+//
+//	eval [argument...]
+//
+// The status is that of the last command run, and exit or return in it take effect as if
+// they were run directly.
+func (s *Shell) handleEval(command *types.Command) (int, bool) {
+	args := command.Args
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	input := strings.Join(args, " ")
+	if strings.TrimSpace(s.stripComments(input)) == "" {
+		return 0, false // Nothing to run
+	}
+	if !parser.IsComplete(s.stripComments(input)) {
+		return s.commandError(&parser.SyntaxError{Message: "unexpected end of file"}, command.ErrorStream)
+	}
+
+	// Lines of the evaluated text count from the line eval is on
+	savedLine := s.inputLine
+	s.inputLine = s.lineNumber
+	exitShell := s.processInput(input, command.InputStream, command.OutputStream, command.ErrorStream)
+	s.inputLine = savedLine
+	return s.lastExitStatus, exitShell
+}
@@ -0,0 +1,226 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// handleExec handles the "exec" command:
+//
+//	exec [-cl] [-a name] [command [argument...]]
+//
+// With a command it replaces the shell by it: -a passes name as its $0, -l puts a '-' in
+// front of $0 as for login shells and -c runs it with an empty environment. Without one,
+// its redirections apply to the shell itself and stay in effect for later commands.
+func (s *Shell) handleExec(command *types.Command) (int, bool) {
+	var name string
+	clean, login := false, false
+	args := command.Args
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case 'c':
+				clean = true
+			case 'l':
+				login = true
+			case 'a':
+				if i+1 < len(arg) {
+					name = arg[i+1:] // -aname
+				} else if len(args) > 0 {
+					name, args = args[0], args[1:]
+				} else {
+					fmt.Fprintln(command.ErrorStream, "exec: -a: option requires an argument")
+					return 2, false
+				}
+				continue options
+			default:
+				fmt.Fprintf(command.ErrorStream, "exec: -%c: invalid option\n", arg[i])
+				fmt.Fprintln(command.ErrorStream, "exec: usage: exec [-cl] [-a name] [command [argument ...]]")
+				return 2, false
+			}
+		}
+	}
+	if len(args) == 0 {
+		return s.redirectShell(command.Descriptors, command.ErrorStream), false
+	}
+
+	environment := s.commandEnvironment(command)
+	if clean {
+		environment = []string{}
+	}
+	path, found := s.findCommand(args[0], environment)
+	if !found {
+		fmt.Fprintf(command.ErrorStream, "exec: %s: not found\n", args[0])
+		return 127, !s.interactive
+	}
+	argv := slices.Clone(args)
+	if name != "" {
+		argv[0] = name
+	}
+	if login {
+		argv[0] = "-" + argv[0]
+	}
+
+	// The command keeps the shell's standard streams, so its redirections are made first
+	streams := map[int]*os.File{0: command.InputStream, 1: command.OutputStream, 2: command.ErrorStream}
+	if status := s.redirectShell(streams, command.ErrorStream); status != 0 {
+		return status, !s.interactive
+	}
+	// So do the descriptors the shell keeps open, and those the command is given
+	kept := maps.Clone(s.descriptors)
+	for fd, file := range command.Descriptors {
+		if fd > 2 {
+			kept[fd] = file
+		}
+	}
+	if err := inheritDescriptors(kept); err != nil {
+		fmt.Fprintf(command.ErrorStream, "exec: %v\n", err)
+		return 1, !s.interactive
+	}
+	err := syscall.Exec(path, argv, environment) // Only returns if the command could not be run
+	fmt.Fprintf(os.Stderr, "exec: %s: %v\n", args[0], err)
+	return 126, !s.interactive
+}
+
+// redirectShell makes the shell's own descriptors refer to the given files, nil closing
+// them. The standard streams are changed in place, other descriptors are kept in the
+// shell's table of open descriptors.
+func (s *Shell) redirectShell(descriptors map[int]*os.File, errors *os.File) int {
+	// Every file is duplicated before any descriptor changes, as in "exec 3>&1 >log" the
+	// file of one descriptor is another one that is about to be redirected
+	duplicates := make(map[int]*os.File)
+	defer func() {
+		for _, duplicate := range duplicates {
+			duplicate.Close()
+		}
+	}()
+	for fd, file := range descriptors {
+		if file == nil || (fd <= 2 && int(file.Fd()) == fd) {
+			continue
+		}
+		duplicate, err := duplicateFile(file)
+		if err != nil {
+			fmt.Fprintf(errors, "exec: %d: %v\n", fd, err)
+			return 1
+		}
+		duplicates[fd] = duplicate
+	}
+
+	for fd := range descriptors {
+		duplicate, ok := duplicates[fd]
+		switch {
+		case fd > 2:
+			if old := s.descriptors[fd]; old != nil {
+				old.Close()
+			}
+			delete(s.descriptors, fd)
+			if ok {
+				s.descriptors[fd] = duplicate
+				delete(duplicates, fd) // Kept open by the shell
+			}
+		case ok:
+			if err := syscall.Dup3(int(duplicate.Fd()), fd, 0); err != nil {
+				fmt.Fprintf(errors, "exec: %d: %v\n", fd, err)
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// Descriptor returns a new file for a descriptor the shell keeps open after exec opened
+// it, which the caller closes.
+func (s *Shell) Descriptor(fd int) (*os.File, bool) {
+	file := s.descriptors[fd]
+	if file == nil {
+		return nil, false
+	}
+	duplicate, err := duplicateFile(file)
+	return duplicate, err == nil
+}
+
+// duplicateFile opens a new descriptor for the same file, which is not inherited by
+// external commands unless they are given it.
+func duplicateFile(file *os.File) (*os.File, error) {
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), file.Name()), nil
+}
+
+// duplicateAbove is duplicateFile for a new descriptor numbered min or higher.
+func duplicateAbove(file *os.File, min int) (*os.File, error) {
+	fd, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), syscall.F_DUPFD_CLOEXEC, uintptr(min))
+	if errno != 0 {
+		return nil, errno
+	}
+	return os.NewFile(fd, file.Name()), nil
+}
+
+// inheritDescriptors gives files their descriptor numbers without close-on-exec, so that
+// a program the process is replaced by inherits them. nil files are left closed.
+func inheritDescriptors(files map[int]*os.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+	// Every file is first moved above the numbers it goes to, as a file may be at the
+	// number another one goes to
+	above := slices.Max(slices.Collect(maps.Keys(files))) + 1
+	moved := make(map[int]*os.File)
+	defer func() {
+		for _, file := range moved {
+			file.Close()
+		}
+	}()
+	for fd, file := range files {
+		if file == nil {
+			continue
+		}
+		duplicate, err := duplicateAbove(file, above)
+		if err != nil {
+			return fmt.Errorf("%d: %w", fd, err)
+		}
+		moved[fd] = duplicate
+	}
+	for fd, file := range moved {
+		if err := syscall.Dup3(int(file.Fd()), fd, 0); err != nil {
+			return fmt.Errorf("%d: %w", fd, err)
+		}
+	}
+	return nil
+}
+
+// childFiles returns the files an external command inherits as descriptors 3 and up: the
+// pipes of its process substitutions, the descriptors the shell keeps open and those of
+// its own redirections, the later ones taking precedence.
+func (s *Shell) childFiles(cmd *types.Command) []*os.File {
+	files := slices.Clone(cmd.ExtraFiles)
+	set := func(fd int, file *os.File) {
+		for len(files) < fd-2 {
+			files = append(files, nil) // nil entries are closed in the child
+		}
+		files[fd-3] = file
+	}
+	for fd, file := range s.descriptors {
+		set(fd, file)
+	}
+	for fd, file := range cmd.Descriptors {
+		if fd > 2 {
+			set(fd, file)
+		}
+	}
+	return files
+}
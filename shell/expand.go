@@ -0,0 +1,29 @@
+package shell
+
+import "strconv"
+
+// commandExpander supplies what parser.ExpandWord needs while expanding the words of a
+// single command: the shell's parameters and the command's process substitutions.
+type commandExpander struct {
+	*Shell
+	*processSubstitutions
+	substitutionStatus int  // Status of the last command substitution, or 0 if there was none
+	substituted        bool // A command substitution ran, so its status is $?
+}
+
+// CommandSubstitution runs a $(list) or `list` substitution. Like any command it sets $?,
+// and its status is kept for a command made only of assignments, which returns it.
+func (e *commandExpander) CommandSubstitution(list string) string {
+	output, status := e.commandSubstitution(list, e.inputStream, e.errorStream)
+	e.substitutionStatus, e.substituted = status, true
+	return output
+}
+
+// Parameter returns the shell's parameters, with $? set by the command substitutions of
+// the command. The other commands of a pipeline, expanded at the same time, don't see it.
+func (e *commandExpander) Parameter(name string) (string, bool) {
+	if name == "?" && e.substituted {
+		return strconv.Itoa(e.substitutionStatus), true
+	}
+	return e.Shell.Parameter(name)
+}
@@ -0,0 +1,225 @@
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/fsutil"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// hashEntry is an external command whose path the hash table remembers.
+type hashEntry struct {
+	path string
+	hits int // Number of times the command was run through the entry
+}
+
+// findCommand finds the path of an external command that runs with environment. Commands
+// found in PATH are remembered in the hash table, which is emptied when PATH changes, so
+// that PATH is only searched again when the remembered file is gone. A PATH the command
+// is given itself, as in "PATH=/bin ls", is searched without the hash table.
+func (s *Shell) findCommand(name string, environment []string) (string, bool) {
+	if path, ok := commandSearchPath(environment); ok {
+		if shellPath, _ := s.Parameter("PATH"); path != shellPath {
+			return fsutil.NewFinder(strings.Split(path, ":")).FindExecutablePath(name)
+		}
+	}
+	finder := s.finder()
+	if strings.Contains(name, "/") {
+		return finder.FindExecutablePath(name)
+	}
+
+	s.pathLock.Lock()
+	entry := s.hashTable[name]
+	s.pathLock.Unlock()
+	if entry != nil && fsutil.IsExecutableFile(entry.path) {
+		s.pathLock.Lock()
+		entry.hits++
+		s.pathLock.Unlock()
+		return entry.path, true
+	}
+
+	path, found := finder.FindExecutablePath(name)
+	s.pathLock.Lock()
+	defer s.pathLock.Unlock()
+	if found {
+		s.hashTable[name] = &hashEntry{path: path, hits: 1}
+	} else {
+		delete(s.hashTable, name)
+	}
+	return path, found
+}
+
+// commandSearchPath returns the value of PATH in the environment of a command.
+func commandSearchPath(environment []string) (string, bool) {
+	path, found := "", false
+	for _, entry := range environment {
+		if value, ok := strings.CutPrefix(entry, "PATH="); ok {
+			path, found = value, true // The last one is the one used
+		}
+	}
+	return path, found
+}
+
+// finder returns the finder for the current value of PATH. PATH is only split into its
+// directories again, and the hash table emptied, when its value changes.
+func (s *Shell) finder() *fsutil.Finder {
+	path, _ := s.Parameter("PATH")
+	s.pathLock.Lock()
+	defer s.pathLock.Unlock()
+	if s.pathFinder == nil || path != s.searchPath {
+		s.pathFinder = fsutil.NewFinder(strings.Split(path, ":"))
+		s.searchPath = path
+		clear(s.hashTable)
+	}
+	return s.pathFinder
+}
+
+// handleHash handles the "hash" command, which shows and changes the hash table:
+//
+//	hash [-lr] [-p path] [-dt] [name...]
+//
+// Names are looked up in PATH and remembered, or with -p remembered as path. -d forgets
+// them and -r forgets all commands. -t prints the remembered paths of the names, -l
+// prints the table as commands that recreate it.
+func (s *Shell) handleHash(command *types.Command) int {
+	var path string
+	var reset, remove, print, reusable, hasPath bool
+	args := command.Args
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+		for i := 1; i < len(arg); i++ {
+			switch arg[i] {
+			case 'r':
+				reset = true
+			case 'd':
+				remove = true
+			case 't':
+				print = true
+			case 'l':
+				reusable = true
+			case 'p':
+				hasPath = true
+				if i+1 < len(arg) {
+					path = arg[i+1:] // -ppath
+				} else if len(args) > 0 {
+					path, args = args[0], args[1:]
+				} else {
+					fmt.Fprintln(command.ErrorStream, "hash: -p: option requires an argument")
+					return 2
+				}
+				continue options
+			default:
+				fmt.Fprintf(command.ErrorStream, "hash: -%c: invalid option\n", arg[i])
+				fmt.Fprintln(command.ErrorStream, "hash: usage: hash [-lr] [-p pathname] [-dt] [name ...]")
+				return 2
+			}
+		}
+	}
+
+	finder := s.finder()
+	if reset {
+		s.pathLock.Lock()
+		clear(s.hashTable)
+		s.pathLock.Unlock()
+	}
+	if len(args) == 0 {
+		if !reset {
+			s.printHashTable(command, reusable)
+		}
+		return 0
+	}
+	if print && !hasPath && !remove {
+		return s.printHashedPaths(command, args, reusable)
+	}
+
+	status := 0
+	for _, name := range args {
+		switch {
+		case remove:
+			s.pathLock.Lock()
+			_, ok := s.hashTable[name]
+			delete(s.hashTable, name)
+			s.pathLock.Unlock()
+			if !ok {
+				fmt.Fprintf(command.ErrorStream, "hash: %s: not found\n", name)
+				status = 1
+			}
+		case hasPath:
+			s.pathLock.Lock()
+			s.hashTable[name] = &hashEntry{path: path}
+			s.pathLock.Unlock()
+		case slices.Contains(s.builtIns, name):
+			// Builtins are not looked up in PATH
+		default:
+			found, ok := finder.FindExecutablePath(name)
+			if !ok {
+				fmt.Fprintf(command.ErrorStream, "hash: %s: not found\n", name)
+				status = 1
+				continue
+			}
+			if strings.Contains(name, "/") {
+				continue // Paths are not remembered
+			}
+			s.pathLock.Lock()
+			s.hashTable[name] = &hashEntry{path: found}
+			s.pathLock.Unlock()
+		}
+	}
+	return status
+}
+
+// printHashTable lists the hash table with the number of times each command was run,
+// or with reusable as hash commands that recreate it.
+func (s *Shell) printHashTable(command *types.Command, reusable bool) {
+	s.pathLock.Lock()
+	defer s.pathLock.Unlock()
+
+	if len(s.hashTable) == 0 {
+		fmt.Fprintln(command.OutputStream, "hash: hash table empty")
+		return
+	}
+	names := slices.Sorted(maps.Keys(s.hashTable))
+	if !reusable {
+		fmt.Fprintln(command.OutputStream, "hits\tcommand")
+	}
+	for _, name := range names {
+		entry := s.hashTable[name]
+		if reusable {
+			fmt.Fprintf(command.OutputStream, "builtin hash -p %s %s\n", entry.path, name)
+		} else {
+			fmt.Fprintf(command.OutputStream, "%4d\t%s\n", entry.hits, entry.path)
+		}
+	}
+}
+
+// printHashedPaths prints the remembered paths of names for "hash -t", preceded by the
+// name when there are several. Returns 1 if any of them is not in the table.
+func (s *Shell) printHashedPaths(command *types.Command, names []string, reusable bool) int {
+	s.pathLock.Lock()
+	defer s.pathLock.Unlock()
+
+	status := 0
+	for _, name := range names {
+		entry := s.hashTable[name]
+		switch {
+		case entry == nil:
+			fmt.Fprintf(command.ErrorStream, "hash: %s: not found\n", name)
+			status = 1
+		case reusable:
+			fmt.Fprintf(command.OutputStream, "builtin hash -p %s %s\n", entry.path, name)
+		case len(names) > 1:
+			fmt.Fprintf(command.OutputStream, "%s\t%s\n", name, entry.path)
+		default:
+			fmt.Fprintln(command.OutputStream, entry.path)
+		}
+	}
+	return status
+}
@@ -60,9 +60,9 @@ func writeHistoryToFile(command *types.Command, history []string, file *os.File)
 }
 
 func (s *Shell) WriteHistoryToFile(command *types.Command, filePath string, append bool, appendStartLine int) {
-	fileOpenBitMask := os.O_CREATE | os.O_WRONLY
+	fileOpenBitMask := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
 	if append {
-		fileOpenBitMask |= os.O_APPEND
+		fileOpenBitMask = os.O_CREATE | os.O_WRONLY | os.O_APPEND
 	}
 
 	file, err := os.OpenFile(filePath, fileOpenBitMask, 0644)
@@ -81,8 +81,8 @@ func (s *Shell) WriteHistoryToFile(command *types.Command, filePath string, appe
 }
 
 // HISTFILE env variable
-func GetHistoryFromEnv() []string {
-	historyFilePath := os.Getenv("HISTFILE")
+func (s *Shell) GetHistoryFromEnv() []string {
+	historyFilePath, _ := s.Parameter("HISTFILE")
 	// fmt.Printf("HISTFILE: %s\n", historyFilePath) // Debugging output
 	if historyFilePath == "" {
 		return make([]string, 0)
@@ -102,7 +102,7 @@ func GetHistoryFromEnv() []string {
 }
 
 func (s *Shell) WriteHistoryToEnv() {
-	historyFilePath := os.Getenv("HISTFILE")
+	historyFilePath, _ := s.Parameter("HISTFILE")
 	if historyFilePath == "" {
 		return
 	}
@@ -111,5 +111,11 @@ func (s *Shell) WriteHistoryToEnv() {
 		return
 	}
 
-	s.WriteHistoryToFile(&types.Command{OutputStream: os.Stdout, ErrorStream: os.Stderr}, historyFilePath, false, -1)
+	command := &types.Command{OutputStream: os.Stdout, ErrorStream: os.Stderr}
+	if s.Option("histappend") {
+		// Only this session's commands are added, other shells may have written to the file
+		s.WriteHistoryToFile(command, historyFilePath, true, max(s.historyFileLength, s.lastAppendTillHistory+1))
+		return
+	}
+	s.WriteHistoryToFile(command, historyFilePath, false, -1)
 }
@@ -0,0 +1,211 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// shellOption is an option of the "set" or "shopt" builtin.
+type shellOption struct {
+	name   string
+	letter byte // turns a set option on with "set -<letter>", 0 if it can only be set by name
+}
+
+// setOptions lists the options of "set -o" in the order they are printed.
+var setOptions = []shellOption{
+	{"errexit", 'e'},            // Exit when a command fails
+	{"interactive-comments", 0}, // '#' starts a comment in interactive input
+	{"noclobber", 'C'},          // ">" does not overwrite existing files
+	{"noexec", 'n'},             // Read commands without running them
+	{"noglob", 'f'},             // No pathname expansion
+	{"nounset", 'u'},            // Expanding an unset parameter is an error
+	{"pipefail", 0},             // A pipeline fails if any of its commands fails
+	{"verbose", 'v'},            // Print input lines as they are read
+	{"xtrace", 'x'},             // Print commands before running them
+}
+
+// shoptOptions lists the options of "shopt" in the order they are printed.
+var shoptOptions = []shellOption{
+	{"autocd", 0},               // A directory name run as a command changes into it
+	{"cdspell", 0},              // cd corrects minor misspellings of directory names
+	{"checkjobs", 0},            // Accepted for compatibility, there are no background jobs
+	{"cmdhist", 0},              // A command spanning several lines is one history entry
+	{"dotglob", 0},              // Wildcards match a leading '.' of a file name
+	{"expand_aliases", 0},       // Accepted for compatibility, there are no aliases
+	{"extglob", 0},              // Patterns like @(a|b) and !(*.o) are recognised
+	{"globstar", 0},             // "**" matches any number of directories
+	{"histappend", 0},           // History is appended to $HISTFILE instead of replacing it
+	{"interactive_comments", 0}, // The "set -o interactive-comments" option under its shopt name
+	{"lastpipe", 0},             // Accepted for compatibility, all commands of a pipeline run in the shell
+	{"nocaseglob", 0},           // Pathname expansion ignores case
+	{"nullglob", 0},             // A pattern without matches expands to nothing
+	{"xpg_echo", 0},             // echo expands backslash escapes without -e
+}
+
+// optionAliases pairs the names of options that set and shopt both have under different
+// names, as bash does.
+var optionAliases = map[string]string{
+	"interactive-comments": "interactive_comments",
+	"interactive_comments": "interactive-comments",
+}
+
+// Option reports whether the named option of set or shopt is turned on.
+func (s *Shell) Option(name string) bool {
+	s.optionsLock.Lock()
+	defer s.optionsLock.Unlock()
+	return s.options[name]
+}
+
+// SetOption turns the named option of set or shopt on or off.
+func (s *Shell) SetOption(name string, on bool) error {
+	if !hasOption(setOptions, name) && !hasOption(shoptOptions, name) {
+		return fmt.Errorf("%s: invalid option name", name)
+	}
+	s.optionsLock.Lock()
+	defer s.optionsLock.Unlock()
+	s.options[name] = on
+	if alias, ok := optionAliases[name]; ok {
+		s.options[alias] = on // Both names turn the same option on or off
+	}
+	return nil
+}
+
+func hasOption(options []shellOption, name string) bool {
+	for _, option := range options {
+		if option.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setOptionByLetter turns the set option with the given letter on or off. Returns false
+// if there is no such option.
+func (s *Shell) setOptionByLetter(letter byte, on bool) bool {
+	for _, option := range setOptions {
+		if option.letter == letter && letter != 0 {
+			s.SetOption(option.name, on)
+			return true
+		}
+	}
+	return false
+}
+
+// optionFlags returns the value of $-, the letters of the options that are turned on.
+func (s *Shell) optionFlags() string {
+	var flags string
+	for _, option := range setOptions {
+		if option.letter != 0 && s.Option(option.name) {
+			flags += string(option.letter)
+		}
+	}
+	if s.interactive {
+		flags += "i"
+	}
+	return flags
+}
+
+// printOptions lists the options for "set -o", or as commands that restore them for "set +o".
+func (s *Shell) printOptions(output io.Writer, asCommands bool) {
+	for _, option := range setOptions {
+		on := s.Option(option.name)
+		if asCommands {
+			fmt.Fprintf(output, "set %s %s\n", onOff(on, "-o", "+o"), option.name)
+		} else {
+			fmt.Fprintf(output, "%-15s\t%s\n", option.name, onOff(on, "on", "off"))
+		}
+	}
+}
+
+// handleSet handles the "set" command. Options start with '-' to turn them on or '+' to
+// turn them off, and the arguments after them, or all arguments after "--", replace the
+// positional parameters. Without arguments it prints the variables.
+func (s *Shell) handleSet(command *types.Command) int {
+	args := command.Args
+	if len(args) == 0 {
+		s.printVariables(command.OutputStream)
+		return 0
+	}
+
+	replaceParams := false
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			args, replaceParams = args[1:], true
+			break
+		}
+		if arg == "-" {
+			// Historical way to end the options, which also turns off -x and -v
+			s.SetOption("xtrace", false)
+			s.SetOption("verbose", false)
+			args = args[1:]
+			break
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+
+		args = args[1:]
+		on := arg[0] == '-'
+		for i := 1; i < len(arg); i++ {
+			if arg[i] != 'o' {
+				if !s.setOptionByLetter(arg[i], on) {
+					fmt.Fprintf(command.ErrorStream, "set: %c%c: invalid option\n", arg[0], arg[i])
+					return 2
+				}
+				continue
+			}
+
+			if len(args) == 0 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[0], "+") {
+				s.printOptions(command.OutputStream, !on) // "set -o" and "set +o" without a name
+				continue
+			}
+			if !hasOption(setOptions, args[0]) {
+				fmt.Fprintf(command.ErrorStream, "set: %s: invalid option name\n", args[0])
+				return 2
+			}
+			s.SetOption(args[0], on)
+			args = args[1:]
+		}
+	}
+
+	if len(args) > 0 || replaceParams {
+		s.replacePositionalParameters(append([]string(nil), args...))
+	}
+	return 0
+}
+
+// traceCommand prints a command for xtrace, prefixed by the expansion of $PS4.
+func (s *Shell) traceCommand(cmd *types.Command, expander parser.Expander) {
+	prefix := "+ "
+	if ps4, ok := s.Parameter("PS4"); ok {
+		prefix, _ = parser.ExpandString(ps4, expander, nil)
+	}
+
+	var words []string
+	for _, assignment := range cmd.Assignments {
+		assignment.Value = parser.Quote(assignment.Value)
+		assignment.Elements = slices.Clone(assignment.Elements)
+		for i, element := range assignment.Elements {
+			assignment.Elements[i] = parser.Quote(element)
+		}
+		words = append(words, assignment.String())
+	}
+	switch cmd.Name {
+	case "":
+	case "[[":
+		// Operands are shown as written, before expansion
+		words = append(append(append(words, cmd.Name), cmd.Args...), "]]")
+	default:
+		for _, word := range append([]string{cmd.Name}, cmd.Args...) {
+			words = append(words, parser.Quote(word))
+		}
+	}
+	fmt.Fprintln(os.Stderr, prefix+strings.Join(words, " "))
+}
@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+
+	builtin "github.com/codecrafters-io/shell-starter-go/builtins"
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// Parameter returns the value of a named, positional or special parameter.
+func (s *Shell) Parameter(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(s.lastExitStatus), true
+	case "#":
+		return strconv.Itoa(len(s.PositionalParameters())), true
+	case "0":
+		return s.scriptName, true
+	case "$":
+		return strconv.Itoa(s.pid), true
+	case "!":
+		// There are no background jobs, but process substitutions run in the background
+		s.variablesLock.Lock()
+		defer s.variablesLock.Unlock()
+		if s.lastSubstitution == 0 {
+			return "", false
+		}
+		return strconv.Itoa(s.lastSubstitution), true
+	case "-":
+		return s.optionFlags(), true
+	case "BASH_COMMAND":
+		return s.currentCommand, true
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		params := s.PositionalParameters()
+		if n < 1 || n > len(params) {
+			return "", false
+		}
+		return params[n-1], true
+	}
+	if arrayName, index, ok := parser.SplitSubscript(name); ok {
+		return s.Element(arrayName, index) // name[index] in arithmetic
+	}
+	return s.lookupVariable(name)
+}
+
+// PositionalParameters returns $1 to $N.
+func (s *Shell) PositionalParameters() []string {
+	s.optionsLock.Lock()
+	defer s.optionsLock.Unlock()
+	return s.positionalParams
+}
+
+// replacePositionalParameters sets $1 to $N and returns the ones they replace.
+func (s *Shell) replacePositionalParameters(params []string) []string {
+	s.optionsLock.Lock()
+	defer s.optionsLock.Unlock()
+	replaced := s.positionalParams
+	s.positionalParams = params
+	return replaced
+}
+
+// SetPositionalParameters sets $0 and the positional parameters the shell starts with.
+func (s *Shell) SetPositionalParameters(name string, args []string) {
+	s.scriptName = name
+	s.positionalParams = args
+}
+
+// handleShift handles the "shift" command.
+func (s *Shell) handleShift(command *types.Command) int {
+	n := 1
+	if len(command.Args) > 0 {
+		var err error
+		n, err = strconv.Atoi(command.Args[0])
+		if err != nil || n < 0 {
+			fmt.Fprintf(command.ErrorStream, "shift: %s: numeric argument required\n", command.Args[0])
+			return 1
+		}
+	}
+
+	s.optionsLock.Lock()
+	defer s.optionsLock.Unlock()
+	if n > len(s.positionalParams) {
+		fmt.Fprintf(command.ErrorStream, "shift: %d: shift count out of range\n", n)
+		return 1
+	}
+	s.positionalParams = s.positionalParams[n:]
+	return 0
+}
+
+// handleRead handles the "read" command, which assigns what it reads to variables.
+func (s *Shell) handleRead(command *types.Command) int {
+	ifs, ok := s.Parameter("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	return builtin.HandleRead(command, builtin.ReadContext{IFS: ifs, SetVariable: s.SetVariable, SetArray: s.setArray,
+		Descriptor: s.Descriptor})
+}
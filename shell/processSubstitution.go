@@ -0,0 +1,93 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// processSubstitutions runs the <(list) and >(list) words of a single command and keeps
+// track of the pipe ends that have to stay open while the command is running.
+type processSubstitutions struct {
+	shell       *Shell
+	inputStream *os.File    // Standard input of the command, which the substituted lists read
+	errorStream *os.File    // Standard error of the pipeline, which the substituted lists write to
+	files       []*os.File  // Pipe ends used by the command, referred to as /dev/fd/N
+	subshells   []*exec.Cmd // Subshells running the lists
+}
+
+func newProcessSubstitutions(s *Shell, inputStream *os.File, errorStream *os.File) *processSubstitutions {
+	return &processSubstitutions{shell: s, inputStream: inputStream, errorStream: errorStream}
+}
+
+// ProcessSubstitution connects list to a pipe and returns the /dev/fd path of the other end.
+func (p *processSubstitutions) ProcessSubstitution(list string, direction byte) string {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating pipe: %v\n", err)
+		return ""
+	}
+
+	commandEnd, listEnd := pipeReader, pipeWriter
+	inputStream, outputStream := p.inputStream, pipeWriter
+	if direction == '>' {
+		commandEnd, listEnd = pipeWriter, pipeReader
+		inputStream, outputStream = pipeReader, os.Stdout
+	}
+
+	// The command's end is given a number above those exec keeps open, which
+	// external commands inherit under the same numbers
+	minimum := 63
+	for fd := range p.shell.descriptors {
+		minimum = max(minimum, fd+1)
+	}
+	file, err := duplicateAbove(commandEnd, minimum)
+	commandEnd.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating pipe: %v\n", err)
+		listEnd.Close()
+		return ""
+	}
+	commandEnd = file
+
+	// The list runs in a subshell, at the same time as the command
+	subshell, err := p.shell.startSubshell(list, inputStream, outputStream, p.errorStream)
+	listEnd.Close() // Only the subshell uses this end, so the command sees it end with the subshell
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting subshell: %v\n", err)
+		commandEnd.Close()
+		return ""
+	}
+	p.files = append(p.files, commandEnd)
+	p.subshells = append(p.subshells, subshell)
+	p.shell.variablesLock.Lock()
+	p.shell.lastSubstitution = subshell.Process.Pid
+	p.shell.variablesLock.Unlock()
+
+	// The same descriptor number is used in the shell and in external commands,
+	// so the path works for builtins and executables alike.
+	return fmt.Sprintf("/dev/fd/%d", commandEnd.Fd())
+}
+
+// extraFiles lays out the pipe ends so that each one keeps its descriptor number in a child.
+func (p *processSubstitutions) extraFiles() []*os.File {
+	var extraFiles []*os.File
+	for _, file := range p.files {
+		fd := int(file.Fd())
+		for len(extraFiles) < fd-2 {
+			extraFiles = append(extraFiles, nil) // nil entries are closed in the child
+		}
+		extraFiles[fd-3] = file
+	}
+	return extraFiles
+}
+
+// wait closes the command's pipe ends and waits for all substituted lists to finish.
+func (p *processSubstitutions) wait() {
+	for _, file := range p.files {
+		file.Close()
+	}
+	for _, subshell := range p.subshells {
+		waitSubshell(subshell)
+	}
+}
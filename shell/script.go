@@ -0,0 +1,234 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+)
+
+// Invocation describes what the shell was asked to run on its command line.
+type Invocation struct {
+	Name       string   // $0
+	Args       []string // Positional parameters
+	Command    string   // Command string given with -c
+	HasCommand bool
+	ScriptPath string // Script file to run, empty to read commands from stdin
+	Login      bool   // Run the profile files, for -l, --login or a name starting with '-'
+	Posix      bool   // Run $ENV instead of the rc files, for --posix or the name sh
+	NoRC       bool   // Do not run the rc files
+	NoProfile  bool   // Do not run the profile files
+	RCFile     string // File run instead of the personal rc file, empty for the default
+	Subshell   int    // Descriptor a subshell reads its state from, see startSubshell; 0 otherwise
+}
+
+// ParseInvocation parses the shell's own command line, program name included:
+//
+//	shell [option...] -c command_string [command_name [argument...]]
+//	shell [option...] [-s] [argument...]
+//	shell [option...] script [argument...]
+//
+// where the options are -l, --login, --norc, --noprofile, --rcfile file and --posix.
+func ParseInvocation(argv []string) (*Invocation, error) {
+	invocation := &Invocation{
+		Name:  argv[0],
+		Login: strings.HasPrefix(argv[0], "-"),
+		Posix: filepath.Base(strings.TrimPrefix(argv[0], "-")) == "sh",
+	}
+	readStdin := false
+
+	args := argv[1:]
+options:
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "--", "-":
+			break options
+		case "--login":
+			invocation.Login = true
+		case "--norc":
+			invocation.NoRC = true
+		case "--noprofile":
+			invocation.NoProfile = true
+		case "--posix":
+			invocation.Posix = true
+		case "--rcfile", "--init-file":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: option requires an argument", arg)
+			}
+			invocation.RCFile, args = args[0], args[1:]
+		case "--subshell":
+			// Started by the shell itself to run a subshell
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s: option requires an argument", arg)
+			}
+			fd, err := strconv.Atoi(args[0])
+			if err != nil || fd < 3 {
+				return nil, fmt.Errorf("%s: %s: invalid descriptor", arg, args[0])
+			}
+			invocation.Subshell, args = fd, args[1:]
+		default:
+			if strings.HasPrefix(arg, "--") {
+				return nil, fmt.Errorf("%s: invalid option", arg)
+			}
+			for _, flag := range arg[1:] {
+				switch flag {
+				case 'c':
+					invocation.HasCommand = true
+				case 's':
+					readStdin = true
+				case 'l':
+					invocation.Login = true
+				default:
+					return nil, fmt.Errorf("-%c: invalid option", flag)
+				}
+			}
+		}
+	}
+
+	switch {
+	case invocation.HasCommand:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("-c: option requires an argument")
+		}
+		invocation.Command, args = args[0], args[1:]
+		if len(args) > 0 {
+			invocation.Name, args = args[0], args[1:]
+		}
+	case !readStdin && len(args) > 0:
+		invocation.ScriptPath, invocation.Name, args = args[0], args[0], args[1:]
+	}
+	invocation.Args = args
+	return invocation, nil
+}
+
+// Execute runs what the invocation asks for and returns the status the shell exits with.
+func (s *Shell) Execute(invocation *Invocation) int {
+	if invocation.Subshell != 0 {
+		return s.runSubshell(invocation.Subshell)
+	}
+	s.SetPositionalParameters(invocation.Name, invocation.Args)
+
+	if invocation.HasCommand || invocation.ScriptPath != "" {
+		s.interactive = false
+	}
+	if s.runStartupFiles(invocation) {
+		return s.runExitTrap(s.lastExitStatus) // exit was run by a startup file
+	}
+
+	var status int
+	switch {
+	case invocation.HasCommand:
+		status = s.RunString(invocation.Command)
+	case invocation.ScriptPath != "":
+		status = s.RunScript(invocation.ScriptPath)
+	default:
+		status = s.Run()
+	}
+	return s.runExitTrap(status)
+}
+
+// RunString runs a command string given with -c.
+func (s *Shell) RunString(command string) int {
+	if !parser.IsComplete(s.stripComments(command)) {
+		status, _ := s.commandError(&parser.SyntaxError{Message: "unexpected end of file"}, os.Stderr)
+		return status
+	}
+	s.processInput(command, os.Stdin, os.Stdout, os.Stderr)
+	return s.lastExitStatus
+}
+
+// RunScript runs the commands of a script file.
+func (s *Shell) RunScript(scriptPath string) int {
+	file, err := os.Open(scriptPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s: No such file or directory\n", os.Args[0], scriptPath)
+		return 127
+	}
+	defer file.Close()
+
+	status, _ := s.runCommands(newLineReader(file), os.Stdin, os.Stdout, os.Stderr)
+	return status
+}
+
+// runCommands executes commands line by line until the input ends or a command exits the
+// shell, with the given standard streams. Lines are
+// joined while a command is incomplete, e.g. inside a multi-line quote. Returns the
+// status of the last command and true if the shell should exit.
+func (s *Shell) runCommands(reader *lineReader, inputStream *os.File, outputStream *os.File, errorStream *os.File) (int, bool) {
+	command := ""
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			fmt.Fprintf(errorStream, "Error reading input: %v\n", err)
+			return 1, false
+		}
+
+		if s.Option("verbose") {
+			fmt.Fprint(errorStream, line)
+		}
+		if lineNumber == 1 && strings.HasPrefix(line, "#!") {
+			line = "" // Interpreter line of a script run through a shebang
+		}
+		if command == "" {
+			s.inputLine = lineNumber
+		}
+		command += line
+		if !parser.IsComplete(s.stripComments(command)) {
+			if err == nil {
+				continue
+			}
+			s.lineNumber = s.inputLine // Reported on the line the incomplete command starts on
+			return s.commandError(&parser.SyntaxError{Message: "unexpected end of file"}, errorStream)
+		}
+
+		if s.processInput(command, inputStream, outputStream, errorStream) {
+			return s.lastExitStatus, true
+		}
+		if err == io.EOF {
+			return s.lastExitStatus, false
+		}
+		command = ""
+	}
+}
+
+// lineReader reads the commands of the shell without reading past the line they end on, so
+// that the commands can read what follows from the same input, as in
+// "printf 'read x\nhello\n' | gosh". Input that can be seeked is read in blocks, and its
+// offset moved back to the end of the line; other input is read one byte at a time.
+type lineReader struct {
+	file   *os.File
+	buffer []byte
+}
+
+func newLineReader(file *os.File) *lineReader {
+	if _, err := file.Seek(0, io.SeekCurrent); err == nil {
+		return &lineReader{file: file, buffer: make([]byte, 4096)}
+	}
+	return &lineReader{file: file, buffer: make([]byte, 1)}
+}
+
+// ReadString reads up to and including delimiter, like bufio.Reader.ReadString.
+func (r *lineReader) ReadString(delimiter byte) (string, error) {
+	var line []byte
+	for {
+		n, err := r.file.Read(r.buffer)
+		if i := bytes.IndexByte(r.buffer[:n], delimiter); i >= 0 {
+			line = append(line, r.buffer[:i+1]...)
+			if i+1 < n { // Left for the next read, by the shell or a command
+				_, err = r.file.Seek(int64(i+1-n), io.SeekCurrent)
+			}
+			return string(line), err
+		}
+		line = append(line, r.buffer[:n]...)
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
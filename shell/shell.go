@@ -2,11 +2,18 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"math/rand"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/chzyer/readline"
 	builtin "github.com/codecrafters-io/shell-starter-go/builtins" // Import builtin package
@@ -19,27 +26,85 @@ import (
 // Shell encapsulates the state and behavior of the shell.
 type Shell struct {
 	builtIns              []string
-	pathFinder            *fsutil.Finder // Use a struct for path management
+	pathFinder            *fsutil.Finder // Searches PATH, use finder() which follows changes of PATH
 	rl                    *readline.Instance
-	CommandsHistory       []string // Store command history for history builtin
-	lastAppendTillHistory int      // Track the last appended index for history
+	CommandsHistory       []string              // Store command history for history builtin
+	lastAppendTillHistory int                   // Track the last appended index for history
+	scriptName            string                // $0, the shell or script name
+	positionalParams      []string              // $1 to $N
+	lastExitStatus        int                   // $?, status of the most recent pipeline
+	interactive           bool                  // Prompts, completion and history are only used when true
+	historyFileLength     int                   // Number of history entries read from $HISTFILE at startup
+	options               map[string]bool       // Options of "set" and "shopt" that are turned on
+	optionsLock           sync.Mutex            // Guards options and positionalParams, which set changes while other commands of a pipeline run
+	variables             map[string]*variable  // Shell variables, exported ones are passed to child processes
+	variablesLock         sync.Mutex            // Guards variables, which builtins in a pipeline use concurrently
+	traps                 map[string]string     // Trap actions by condition, e.g. "EXIT" or "SIGINT"
+	trapsLock             sync.Mutex            // Guards traps, which trap changes while other commands of a pipeline run
+	signals               chan os.Signal        // Caught signals waiting for their traps to run
+	inTrap                bool                  // A trap is running, so no other trap runs
+	currentCommand        string                // BASH_COMMAND, the pipeline being run
+	startTime             time.Time             // When the shell started
+	secondsStart          time.Time             // When SECONDS was 0
+	random                *rand.Rand            // Source of RANDOM
+	inputLine             int                   // Line number of the start of the input being run
+	lineNumber            int                   // LINENO, the line of the pipeline being run
+	sourceFile            string                // File being run by source, named in error messages
+	sourceDepth           int                   // Number of nested source commands running
+	returning             bool                  // return was run and ends the sourced file
+	descriptors           map[int]*os.File      // Descriptors above 2 opened by exec, by number
+	hashTable             map[string]*hashEntry // Paths of the external commands run so far, see findCommand
+	searchPath            string                // PATH the finder and hash table were set up for
+	pathLock              sync.Mutex            // Guards the finder and hash table, which commands in a pipeline use concurrently
+	completionTrie        *trie.TrieNode        // Command names for completion, see commandTrie
+	completionFinder      *fsutil.Finder        // Finder the completion trie was built with
+	completionTime        time.Time             // When the completion trie was built
+	pid                   int                   // $$, the process ID of the shell, which subshells share
+	lastSubstitution      int                   // $!, process ID of the last process substitution, guarded by variablesLock
 }
 
+// specialBuiltIns are the POSIX special builtins; errors in them abort a non-interactive shell.
+var specialBuiltIns = []string{":", ".", "eval", "exec", "exit", "export", "readonly", "return",
+	"set", "shift", "trap", "unset"}
+
+// statusBuiltIns are special builtins whose status is not only set by errors, such as the
+// status of the commands "." runs or the one return is given. They decide themselves
+// which errors abort the shell.
+var statusBuiltIns = []string{".", "eval", "return"}
+
 // NewShell creates and initializes a new Shell instance.
 func NewShell() *Shell {
-	builtIns := []string{"echo", "type", "exit", "pwd", "cd", "history"}
-	pathFinder := fsutil.NewFinder(strings.Split(os.Getenv("PATH"), ":")) // Initialize path finder
+	builtIns := []string{"echo", "type", "exit", "pwd", "cd", "history", "set", "shift", "shopt", "test", "trap", "read", "printf",
+		"declare", "typeset", "export", "readonly", "unset", "env", "source", ".", "return", "eval", "exec", "command", "builtin", "hash", "[", "[[", ":"}
 
-	allCommands := make([]string, 0)
-	allCommands = append(allCommands, builtIns...)
-	allCommands = append(allCommands, pathFinder.GetExecutables()...) // Get executables from PATH
+	s := &Shell{
+		builtIns:              builtIns,
+		lastAppendTillHistory: -1, // Initialize last appended index for history
+		scriptName:            os.Args[0],
+		interactive:           readline.IsTerminal(int(os.Stdin.Fd())), // Read commands from a user, not a pipe or file
+		options:               map[string]bool{"interactive-comments": true, "interactive_comments": true, "cmdhist": true},
+		variables:             loadEnvironment(),
+		traps:                 make(map[string]string),
+		descriptors:           make(map[int]*os.File),
+		hashTable:             make(map[string]*hashEntry),
+		signals:               make(chan os.Signal, 16),
+		startTime:             time.Now(),
+		inputLine:             1,
+		pid:                   os.Getpid(),
+	}
+	s.addSpecialVariables()
+	return s
+}
 
+// newReadline sets up line editing with tab completion for the interactive loop.
+func (s *Shell) newReadline() *readline.Instance {
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          "$ ",
-		InterruptPrompt: "^C",   // Text to show on Ctrl+C
-		EOFPrompt:       "exit", // Text to show on Ctrl+D
+		Prompt:                 "$ ",
+		InterruptPrompt:        "^C",   // Text to show on Ctrl+C
+		EOFPrompt:              "exit", // Text to show on Ctrl+D
+		DisableAutoSaveHistory: true,   // ReadInput saves whole commands, not single lines
 		AutoComplete: &TabCompleter{
-			trie:                           trie.NewTrieNode(allCommands), // Initialize trie with all commands
+			commands:                       s.commandTrie, // Builtins and the executables in PATH
 			tabPressedAfterMultipleResults: false,
 		},
 	})
@@ -48,13 +113,7 @@ func NewShell() *Shell {
 		fmt.Fprintf(os.Stderr, "Error initializing readline: %v\n", err)
 		os.Exit(1) // Cannot run interactive shell without readline
 	}
-	return &Shell{
-		builtIns:              builtIns,
-		pathFinder:            pathFinder,
-		rl:                    rl,
-		CommandsHistory:       GetHistoryFromEnv(), // Initialize command history
-		lastAppendTillHistory: -1,                  // Initialize last appended index for history
-	}
+	return rl
 }
 
 func (s *Shell) ReadInput() (string, error) {
@@ -63,15 +122,53 @@ func (s *Shell) ReadInput() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("error reading input: %w", err)
 	}
+
+	// Keep reading with the PS2 prompt until quotes, pipes and the like are closed
+	for !parser.IsComplete(s.stripComments(line)) {
+		s.rl.SetPrompt(s.continuationPrompt())
+		nextLine, err := s.rl.Readline()
+		s.rl.SetPrompt("$ ")
+		if err != nil {
+			return "", fmt.Errorf("error reading input: %w", err)
+		}
+		line += "\n" + nextLine
+	}
+
+	if s.Option("verbose") {
+		fmt.Fprintln(os.Stderr, line)
+	}
 	if line != "" {
 		line = strings.TrimSpace(line) // Trim whitespace from the input
-		s.CommandsHistory = append(s.CommandsHistory, line)
+		entries := []string{line}      // With cmdhist a multi-line command is recalled as one entry
+		if !s.Option("cmdhist") {
+			entries = strings.Split(line, "\n")
+		}
+		for _, entry := range entries {
+			s.CommandsHistory = append(s.CommandsHistory, entry)
+			s.rl.SaveHistory(entry)
+		}
 	}
 	return line, nil
 }
 
-// Run starts the shell's main loop.
-func (s *Shell) Run() {
+// continuationPrompt returns the prompt shown while a command spans several lines.
+func (s *Shell) continuationPrompt() string {
+	if ps2, ok := s.Parameter("PS2"); ok {
+		return ps2
+	}
+	return "> "
+}
+
+// Run starts the shell's main loop and returns the status the shell exits with.
+func (s *Shell) Run() int {
+	if !s.interactive {
+		status, _ := s.runCommands(newLineReader(os.Stdin), os.Stdin, os.Stdout, os.Stderr) // No prompts when commands are piped in
+		return status
+	}
+
+	s.rl = s.newReadline()                    // Only the interactive loop reads stdin through readline
+	s.CommandsHistory = s.GetHistoryFromEnv() // Initialize command history
+	s.historyFileLength = len(s.CommandsHistory)
 	defer s.rl.Close()          // Ensure readline is closed when done
 	defer s.WriteHistoryToEnv() // Write command history to environment on exit
 
@@ -89,11 +186,13 @@ func (s *Shell) Run() {
 		}
 
 		// Process the command
-		exitShell := s.processInput(commandInput)
+		exitShell := s.processInput(commandInput, os.Stdin, os.Stdout, os.Stderr)
 		if exitShell {
 			break
 		}
+		s.inputLine += strings.Count(commandInput, "\n") + 1
 	}
+	return s.lastExitStatus
 }
 
 // printPrompt prints the shell prompt to stdout.
@@ -101,17 +200,88 @@ func (s *Shell) printPrompt() {
 	fmt.Fprint(os.Stdout, "$ ")
 }
 
-func (s *Shell) processInput(input string) bool {
+// processInput runs a command list, honouring ;, && and || between its pipelines, with
+// the given standard streams. Returns true if the shell should exit.
+func (s *Shell) processInput(input string, inputStream *os.File, outputStream *os.File, errorStream *os.File) bool {
+	list, err := parser.GetList(s.stripComments(input))
+	if err != nil {
+		var exitShell bool
+		s.lastExitStatus, exitShell = s.commandError(err, errorStream)
+		return exitShell
+	}
+	operator := ""
+	for _, item := range list {
+		if s.Option("noexec") && !s.interactive {
+			return false // Commands are only checked for syntax errors, "set -n" may be one of them
+		}
+		if s.runPendingTraps() { // Traps for signals run between commands
+			return true
+		}
+		skip := (operator == "&&" && s.lastExitStatus != 0) || (operator == "||" && s.lastExitStatus == 0)
+		operator = item.Operator
+		if skip {
+			continue
+		}
+
+		s.lineNumber = s.inputLine + item.Line
+		status, exitShell := s.processPipeline(item.Pipeline, inputStream, outputStream, errorStream)
+		if item.Negated {
+			status = negate(status)
+		}
+		s.lastExitStatus = status
+		if exitShell {
+			return true
+		}
+		// A failure runs the ERR trap and ends the shell with errexit, unless it is tested by
+		// &&, || or !
+		if status != 0 && !item.Negated && item.Operator != "&&" && item.Operator != "||" {
+			if s.runTrap("ERR") || s.Option("errexit") {
+				return true
+			}
+		}
+	}
+	return s.runPendingTraps()
+}
+
+// negate returns the status of a pipeline preceded by "!": 0 if it failed, 1 if not.
+func negate(status int) int {
+	if status == 0 {
+		return 1
+	}
+	return 0
+}
+
+// stripComments removes comments from input, unless interactive comments are turned off.
+func (s *Shell) stripComments(input string) string {
+	if s.interactive && !s.Option("interactive-comments") {
+		return input
+	}
+	return parser.StripComments(input)
+}
+
+// processPipeline runs the commands of a pipeline concurrently. Returns the exit status
+// of the last command and true if the shell should exit.
+func (s *Shell) processPipeline(input string, inputStream *os.File, outputStream *os.File, errorStream *os.File) (int, bool) {
 	commandStrings := parser.GetCommands(input)
 	// fmt.Fprintf(os.Stdout, "commandStrings: %v\n", commandStrings) // Debugging output
+	if len(commandStrings) == 0 {
+		return 0, false
+	}
+
+	if !s.inTrap {
+		s.currentCommand = strings.TrimSpace(input)
+		if s.runTrap("DEBUG") { // The DEBUG trap runs before each pipeline
+			return s.lastExitStatus, true
+		}
+	}
 
 	inputStreams := make([]*os.File, len(commandStrings))
 	outputStreams := make([]*os.File, len(commandStrings))
 
 	// Set default input and output streams for each command
 	for i := range commandStrings {
-		inputStreams[i] = os.Stdin
-		outputStreams[i] = os.Stdout
+		inputStreams[i] = inputStream
+		outputStreams[i] = outputStream
 	}
 
 	// Connect output streams of previous commands to input streams of next commands
@@ -119,112 +289,253 @@ func (s *Shell) processInput(input string) bool {
 		pipeReader, pipeWriter, err := os.Pipe()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating pipe: %v\n", err)
-			return false // Continue the shell, but log the error
+			return 1, false // Continue the shell, but log the error
 		}
 		inputStreams[i+1] = pipeReader // Set the next command's input to the pipe reader
 		outputStreams[i] = pipeWriter  // Set the current command's output to the pipe writer
 	}
 
-	commands := make([]*types.Command, len(commandStrings))
-	for i, cmdStr := range commandStrings {
-		commands[i] = parser.ParseCommand(cmdStr, inputStreams[i], outputStreams[i])
-	}
+	// Streams the pipeline was given belong to the caller and stay open
+	sharedStreams := []*os.File{inputStream, outputStream, errorStream, os.Stdin, os.Stdout, os.Stderr}
 
 	var wgExecute sync.WaitGroup
-	exitCodes := make([]bool, len(commands))
-	for idx, cmd := range commands {
+	exitCodes := make([]int, len(commandStrings))
+	exitShell := make([]bool, len(commandStrings))
+	for idx, cmdStr := range commandStrings {
 		wgExecute.Add(1)
-		go func(cmd *types.Command) {
+		go func() {
 			defer wgExecute.Done()
-			exitCodes[idx] = s.processCommand(cmd)
-		}(cmd)
+			substitutions := newProcessSubstitutions(s, inputStreams[idx], errorStream)
+			defer substitutions.wait() // Process substitutions live as long as their command
+			defer closeOwnedStreams(sharedStreams, inputStreams[idx], outputStreams[idx])
+
+			// Each command is expanded while the others run, so that its command
+			// substitutions can read what the commands before it write to the pipe
+			expander := &commandExpander{Shell: s, processSubstitutions: substitutions}
+			cmd, err := parser.ParseCommand(cmdStr, inputStreams[idx], outputStreams[idx], errorStream, expander)
+			if err != nil {
+				exitCodes[idx], exitShell[idx] = s.commandError(err, errorStream)
+				return
+			}
+			if cmd == nil { // Handle cases where parser returns nil (e.g., only redirects or empty)
+				return
+			}
+			cmd.ExtraFiles = substitutions.extraFiles()
+			if s.Option("xtrace") {
+				s.traceCommand(cmd, expander)
+			}
+			defer closeOwnedStreams(sharedStreams, cmd.InputStream, cmd.OutputStream, cmd.ErrorStream)
+			defer closeOwnedStreams(sharedStreams, slices.Collect(maps.Values(cmd.Descriptors))...)
+
+			if cmd.Name == "" {
+				// The assignments were made while the command was expanded
+				exitCodes[idx] = expander.substitutionStatus
+				return
+			}
+			exitCodes[idx], exitShell[idx] = s.runCommand(cmd)
+			if exitCodes[idx] != 0 && !s.interactive && slices.Contains(specialBuiltIns, cmd.Name) &&
+				!slices.Contains(statusBuiltIns, cmd.Name) {
+				exitShell[idx] = true // Errors in special builtins abort a non-interactive shell
+			}
+		}()
 	}
 	wgExecute.Wait() // Wait for all commands to finish executing
 
-	return exitCodes[len(exitCodes)-1] // Return the exit code of the last command
+	last := len(commandStrings) - 1
+	if s.Option("pipefail") {
+		// The status is that of the last command that failed, if any did
+		for i := last; i >= 0; i-- {
+			if exitCodes[i] != 0 {
+				return exitCodes[i], exitShell[last]
+			}
+		}
+	}
+	return exitCodes[last], exitShell[last] // Return the exit code of the last command
 }
 
-// processCommand parses and executes a command. Returns true if the shell should exit.
-func (s *Shell) processCommand(cmd *types.Command) bool {
-	if cmd == nil { // Handle cases where parser returns nil (e.g., only redirects or empty)
-		return false
+// closeOwnedStreams closes redirected files and pipe ends, leaving the shared streams open.
+func closeOwnedStreams(sharedStreams []*os.File, streams ...*os.File) {
+	for _, stream := range streams {
+		if !slices.Contains(sharedStreams, stream) {
+			stream.Close()
+		}
 	}
+}
 
-	defer func() {
-		// Close redirected files if they were opened
-		if cmd.InputStream != os.Stdin {
-			cmd.InputStream.Close()
-		}
-		if cmd.OutputStream != os.Stdout && cmd.OutputStream != os.Stderr {
-			cmd.OutputStream.Close()
-		}
-		if cmd.ErrorStream != os.Stdout && cmd.ErrorStream != os.Stderr {
-			cmd.ErrorStream.Close()
-		}
-	}()
+// commandError reports an error that stopped a command from running on errorStream. Returns the exit
+// status and true if the shell should exit, which non-interactive shells do for syntax
+// and expansion errors.
+func (s *Shell) commandError(err error, errorStream *os.File) (int, bool) {
+	fmt.Fprintf(errorStream, "%s%v\n", s.errorLocation(), err)
 
+	var syntaxErr *parser.SyntaxError
+	var expansionErr *parser.ExpansionError
+	var assignmentErr *parser.AssignmentError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return 2, !s.interactive
+	case errors.As(err, &expansionErr), errors.As(err, &assignmentErr):
+		return 1, !s.interactive
+	default:
+		return 1, false
+	}
+}
+
+// processCommand executes a parsed command. Returns its exit status and true if the shell should exit.
+func (s *Shell) processCommand(cmd *types.Command) (int, bool) {
 	switch cmd.Name {
 	case "exit":
-		return true // Exit the shell
+		return s.handleExit(cmd), true // Exit the shell
 	case "echo":
-		builtin.HandleEcho(cmd)
+		return builtin.HandleEcho(cmd, s.Option("xpg_echo")), false
 	case "type":
-		builtin.HandleType(cmd, s.pathFinder, s.builtIns) // Pass the pathFinder instance
+		return builtin.HandleType(cmd, s.finder(), s.builtIns), false // Pass the pathFinder instance
 	case "pwd":
-		builtin.HandlePwd(cmd)
+		return builtin.HandlePwd(cmd), false
 	case "cd":
-		builtin.HandleCd(cmd, s.pathFinder) // Pass the pathFinder instance
+		return builtin.HandleCd(cmd, s.finder(), s.cdContext(s.Option("cdspell") && s.interactive)), false // Pass the pathFinder instance
 	case "history":
-		s.handleHistory(cmd) // Pass the command history
+		return s.handleHistory(cmd), false // Pass the command history
+	case "set":
+		return s.handleSet(cmd), false
+	case "shopt":
+		return s.handleShopt(cmd), false
+	case "trap":
+		return s.handleTrap(cmd), false
+	case "read":
+		return s.handleRead(cmd), false
+	case "declare", "typeset":
+		return s.handleDeclare(cmd), false
+	case "export":
+		return s.handleExport(cmd), false
+	case "readonly":
+		return s.handleReadonly(cmd), false
+	case "unset":
+		return s.handleUnset(cmd), false
+	case "env":
+		return s.handleEnv(cmd), false
+	case "printf":
+		return builtin.HandlePrintf(cmd, builtin.PrintfContext{SetVariable: s.SetVariable, StartTime: s.startTime}), false
+	case "shift":
+		return s.handleShift(cmd), false
+	case "source", ".":
+		return s.handleSource(cmd)
+	case "return":
+		return s.handleReturn(cmd)
+	case "eval":
+		return s.handleEval(cmd)
+	case "exec":
+		return s.handleExec(cmd)
+	case "command":
+		return s.handleCommand(cmd)
+	case "builtin":
+		return s.handleBuiltin(cmd)
+	case "hash":
+		return s.handleHash(cmd), false
+	case "[[":
+		return s.handleConditional(cmd), false
+	case "test", "[":
+		return builtin.HandleTest(cmd), false
+	case ":":
+		return 0, false // Only its arguments are expanded and its redirections made
 	default:
+		if s.Option("autocd") && s.interactive && len(cmd.Args) == 0 && isDirectory(cmd.Name) {
+			// A directory name alone is run as if it was the argument of cd
+			fmt.Fprintf(cmd.ErrorStream, "cd -- %s\n", cmd.Name)
+			return builtin.HandleCd(&types.Command{Name: "cd", Args: []string{cmd.Name}, InputStream: cmd.InputStream,
+				OutputStream: cmd.OutputStream, ErrorStream: cmd.ErrorStream}, s.finder(), s.cdContext(false)), false
+		}
 		// Attempt to execute as an external command
-		s.executeExternalCommand(cmd)
+		return s.executeExternalCommand(cmd, s.commandEnvironment(cmd)), false
 	}
-	return false // Continue the shell
+}
+
+// cdContext gives cd access to the shell's variables.
+func (s *Shell) cdContext(correctSpelling bool) builtin.CdContext {
+	return builtin.CdContext{Parameter: s.Parameter, SetVariable: s.SetVariable, CorrectSpelling: correctSpelling}
 }
 
 func (s *Shell) GetCommandsHistory() []string {
 	return s.CommandsHistory
 }
 
-func (s *Shell) handleHistory(command *types.Command) {
+func (s *Shell) handleHistory(command *types.Command) int {
 	for i, arg := range command.Args {
 		if arg == "-r" {
-			if i+1 < len(command.Args) {
-				s.LoadHistoryFromFile(command, command.Args[i+1])
-			} else {
+			if i+1 >= len(command.Args) {
 				fmt.Fprintln(command.ErrorStream, "history: missing file path after -r")
+				return 1
 			}
-			return
+			s.LoadHistoryFromFile(command, command.Args[i+1])
+			return 0
 		} else if arg == "-w" || arg == "-a" {
-			if i+1 < len(command.Args) {
-				s.WriteHistoryToFile(command, command.Args[i+1], arg == "-a", s.lastAppendTillHistory+1)
-			} else {
+			if i+1 >= len(command.Args) {
 				fmt.Fprintln(command.ErrorStream, "history: missing file path after -w or -a")
+				return 1
 			}
-			return
+			s.WriteHistoryToFile(command, command.Args[i+1], arg == "-a", s.lastAppendTillHistory+1)
+			return 0
 		}
 	}
 
-	builtin.HandleHistory(command, s.CommandsHistory)
+	return builtin.HandleHistory(command, s.CommandsHistory)
 }
 
-// executeExternalCommand finds and runs an external command.
-func (s *Shell) executeExternalCommand(cmd *types.Command) {
-	strippedCommand := parser.StripSurroundingQuotes(cmd.Name) // Strip quotes for command name
-	path, found := s.pathFinder.FindExecutablePath(strippedCommand)
-	if !found {
-		fmt.Fprintf(cmd.ErrorStream, "%s: command not found\n", cmd.Name)
-		return
+// handleExit works out the status the shell exits with.
+func (s *Shell) handleExit(command *types.Command) int {
+	if len(command.Args) == 0 {
+		return s.lastExitStatus
 	}
+	status, err := strconv.Atoi(command.Args[0])
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "exit: %s: numeric argument required\n", command.Args[0])
+		return 2
+	}
+	return status & 0xff
+}
 
-	commandToRun := cmd.Name
-	if strippedCommand != cmd.Name {
-		commandToRun = path // Use the full path if command was quoted
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// executeExternalCommand finds and runs an external command with the given environment
+// and returns its exit status.
+func (s *Shell) executeExternalCommand(cmd *types.Command, environment []string) int {
+	path, found := s.findCommand(cmd.Name, environment)
+	if !found {
+		fmt.Fprintf(cmd.ErrorStream, "%s%s: command not found\n", s.errorLocation(), cmd.Name)
+		return 127
 	}
-	execCmd := exec.Command(commandToRun, cmd.Args...)
+	return s.runExecutable(cmd, path, environment)
+}
+
+// runExecutable runs the program at path for an external command and returns its exit
+// status.
+func (s *Shell) runExecutable(cmd *types.Command, path string, environment []string) int {
+	execCmd := exec.Command(path, cmd.Args...)
+	execCmd.Args[0] = cmd.Name // Programs see the name they were invoked with
+	execCmd.Env = environment
 	execCmd.Stdout = cmd.OutputStream
 	execCmd.Stderr = cmd.ErrorStream
 	execCmd.Stdin = cmd.InputStream
-	execCmd.Run()
+	execCmd.ExtraFiles = s.childFiles(cmd)
+	return exitStatus(cmd, execCmd.Run())
+}
+
+// exitStatus converts the result of running an external command into a shell exit status.
+func exitStatus(cmd *types.Command, err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()) // Killed by a signal
+		}
+		return exitErr.ExitCode()
+	default:
+		fmt.Fprintf(cmd.ErrorStream, "%s: %v\n", cmd.Name, err)
+		return 126 // Found but could not be executed
+	}
 }
@@ -0,0 +1,101 @@
+package shell
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// handleShopt handles the "shopt" command:
+//
+//	shopt [-s | -u] [-pqo] [optname...]
+//
+// -s and -u turn the named options on and off, or list the options that are on or off
+// when no names are given. Otherwise the options are printed, as commands that restore
+// them with -p or not at all with -q, and the status tells whether all of them are on.
+// -o works on the options of "set -o" instead.
+func (s *Shell) handleShopt(command *types.Command) int {
+	var turnOn, turnOff, asCommands, quiet, setOption bool
+	args := command.Args
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 's':
+				turnOn = true
+			case 'u':
+				turnOff = true
+			case 'p':
+				asCommands = true
+			case 'q':
+				quiet = true
+			case 'o':
+				setOption = true
+			default:
+				fmt.Fprintf(command.ErrorStream, "shopt: -%c: invalid option\n", flag)
+				fmt.Fprintln(command.ErrorStream, "shopt: usage: shopt [-pqsu] [-o] [optname ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+	if turnOn && turnOff {
+		fmt.Fprintln(command.ErrorStream, "shopt: cannot set and unset shell options simultaneously")
+		return 1
+	}
+
+	options := shoptOptions
+	if setOption {
+		options = setOptions
+	}
+	for _, name := range args {
+		if !hasOption(options, name) {
+			fmt.Fprintf(command.ErrorStream, "shopt: %s: invalid shell option name\n", name)
+			return 1
+		}
+	}
+
+	if (turnOn || turnOff) && len(args) > 0 {
+		for _, name := range args {
+			s.SetOption(name, turnOn)
+		}
+		return 0
+	}
+
+	names := args
+	if len(names) == 0 {
+		for _, option := range options {
+			if on := s.Option(option.name); (!turnOn || on) && (!turnOff || !on) {
+				names = append(names, option.name)
+			}
+		}
+	}
+
+	status := 0
+	for _, name := range names {
+		on := s.Option(name)
+		if !on && len(args) > 0 {
+			status = 1
+		}
+		switch {
+		case quiet:
+		case asCommands && setOption:
+			fmt.Fprintf(command.OutputStream, "set %s %s\n", onOff(on, "-o", "+o"), name)
+		case asCommands:
+			fmt.Fprintf(command.OutputStream, "shopt %s %s\n", onOff(on, "-s", "-u"), name)
+		default:
+			fmt.Fprintf(command.OutputStream, "%-15s\t%s\n", name, onOff(on, "on", "off"))
+		}
+	}
+	return status
+}
+
+func onOff(on bool, ifOn string, ifOff string) string {
+	if on {
+		return ifOn
+	}
+	return ifOff
+}
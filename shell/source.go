@@ -0,0 +1,139 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// handleSource handles the "source" and "." commands, which run the commands of a file in
+// the current shell. Arguments replace the positional parameters while the file runs:
+//
+//	source filename [argument...]
+func (s *Shell) handleSource(command *types.Command) (int, bool) {
+	args := command.Args
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	// Errors of "." abort a non-interactive shell like those of other special builtins
+	abort := command.Name == "." && !s.interactive
+	if len(args) == 0 {
+		fmt.Fprintf(command.ErrorStream, "%s: filename argument required\n", command.Name)
+		fmt.Fprintf(command.ErrorStream, "%s: usage: %s filename [arguments]\n", command.Name, command.Name)
+		return 2, abort
+	}
+
+	path, found := s.findSourceFile(args[0])
+	if !found {
+		fmt.Fprintf(command.ErrorStream, "%s: %s: No such file or directory\n", command.Name, args[0])
+		return 1, abort
+	}
+	file, err := s.openSourceFile(path, command)
+	if err != nil {
+		fmt.Fprintf(command.ErrorStream, "%s: %s: %v\n", command.Name, args[0], err)
+		return 1, abort
+	}
+	defer file.Close()
+
+	if len(args) > 1 {
+		savedParams := s.replacePositionalParameters(args[1:])
+		defer s.replacePositionalParameters(savedParams)
+	}
+	status, exitShell := s.runSourced(path, file, command.InputStream, command.OutputStream, command.ErrorStream)
+	if !exitShell && s.runTrap("RETURN") {
+		return s.lastExitStatus, true
+	}
+	return status, exitShell
+}
+
+// runSourced runs the commands of a file in the current shell with the given standard
+// streams until it ends or return is run. Returns the status of the last command
+// and true if the shell should exit.
+func (s *Shell) runSourced(path string, file *os.File, inputStream *os.File, outputStream *os.File, errorStream *os.File) (int, bool) {
+	savedFile, savedLine := s.sourceFile, s.inputLine
+	s.sourceFile = path
+	s.sourceDepth++
+	status, exitShell := s.runCommands(newLineReader(file), inputStream, outputStream, errorStream)
+	s.sourceDepth--
+	s.sourceFile, s.inputLine = savedFile, savedLine
+
+	if s.returning {
+		s.returning, exitShell = false, false
+	}
+	return status, exitShell
+}
+
+// findSourceFile finds the file source runs. A name without a slash is looked up in PATH
+// and then in the current directory. Besides regular files, source reads pipes and devices,
+// as in "source <(list)" or ". /dev/stdin".
+func (s *Shell) findSourceFile(name string) (string, bool) {
+	if strings.Contains(name, "/") {
+		return name, isSourceFile(name)
+	}
+	if path, ok := s.Parameter("PATH"); ok {
+		for _, dir := range strings.Split(path, ":") {
+			if dir == "" {
+				dir = "." // An empty entry is the current directory
+			}
+			if candidate := filepath.Join(dir, name); isSourceFile(candidate) {
+				return candidate, true
+			}
+		}
+	}
+	return name, isSourceFile(name)
+}
+
+// openSourceFile opens the file source runs. /dev/stdin is the input of the command,
+// which in a pipeline is not that of the shell.
+func (s *Shell) openSourceFile(path string, command *types.Command) (*os.File, error) {
+	if path == "/dev/stdin" || path == "/dev/fd/0" {
+		return duplicateFile(command.InputStream)
+	}
+	return os.Open(path)
+}
+
+// isSourceFile reports whether path names a file that source can read from.
+func isSourceFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// handleReturn handles the "return" command, which ends a sourced file with the given
+// status or that of the last command:
+//
+//	return [n]
+func (s *Shell) handleReturn(command *types.Command) (int, bool) {
+	if s.sourceDepth == 0 {
+		fmt.Fprintln(command.ErrorStream, "return: can only `return' from a function or sourced script")
+		return 2, false
+	}
+	status := s.lastExitStatus
+	if len(command.Args) > 0 {
+		n, err := strconv.Atoi(command.Args[0])
+		if err != nil {
+			fmt.Fprintf(command.ErrorStream, "return: %s: numeric argument required\n", command.Args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	s.returning = true // Ends the sourced file like exit, see handleSource
+	return status, true
+}
+
+// errorLocation returns the "file: line N: " prefix of error messages about commands of
+// a sourced file, or "" outside of one.
+func (s *Shell) errorLocation() string {
+	if s.sourceFile == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s: line %d: ", s.sourceFile, s.lineNumber)
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/codecrafters-io/shell-starter-go/parser"
+)
+
+// shellName names the shell's startup files, e.g. /etc/goshrc and ~/.goshrc.
+const shellName = "gosh"
+
+// runStartupFiles runs the files that set up the shell before it reads commands, in the
+// order bash does:
+//
+//   - login shells run /etc/gosh_profile and then the first of ~/.gosh_profile,
+//     ~/.gosh_login and ~/.profile that exists; /etc/profile is written for other shells
+//   - interactive shells that are not login shells run /etc/goshrc and ~/.goshrc, or the
+//     --rcfile file instead of the latter
+//   - interactive shells in POSIX mode run the file named by $ENV instead of any rc file
+//
+// Errors are reported with the file and line they happened on but do not stop the shell
+// from starting. Returns true if a startup file ran exit.
+func (s *Shell) runStartupFiles(invocation *Invocation) bool {
+	home, _ := s.Parameter("HOME")
+	var files []string
+	if invocation.Login && !invocation.NoProfile {
+		files = append(files, "/etc/"+shellName+"_profile")
+		for _, name := range []string{"." + shellName + "_profile", "." + shellName + "_login", ".profile"} {
+			if path := filepath.Join(home, name); home != "" && isRegularFile(path) {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	if s.interactive && !invocation.NoRC {
+		switch env, ok := s.Parameter("ENV"); {
+		case invocation.Posix && ok:
+			expander := &commandExpander{Shell: s, processSubstitutions: newProcessSubstitutions(s, os.Stdin, os.Stderr)}
+			if path, err := parser.ExpandString(env, expander, nil); err == nil {
+				files = append(files, path)
+			}
+			expander.wait()
+		case invocation.Posix, invocation.Login:
+		case invocation.RCFile != "":
+			files = append(files, "/etc/"+shellName+"rc", invocation.RCFile)
+		default:
+			files = append(files, "/etc/"+shellName+"rc")
+			if home != "" {
+				files = append(files, filepath.Join(home, "."+shellName+"rc"))
+			}
+		}
+	}
+
+	for _, path := range files {
+		if s.runStartupFile(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// runStartupFile runs a startup file if it exists. Returns true if it ran exit.
+func (s *Shell) runStartupFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false // Missing startup files are skipped
+	}
+	defer file.Close()
+
+	_, exitShell := s.runSourced(path, file, os.Stdin, os.Stdout, os.Stderr)
+	// Only exit ends an interactive shell. Errors that end a non-interactive one only end
+	// the startup file, so that the shell still starts.
+	return exitShell && s.interactive
+}
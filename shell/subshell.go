@@ -0,0 +1,191 @@
+package shell
+
+import (
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// subshellState is what a subshell starts out with of the shell that started it. Subshells
+// run as separate processes of the shell's own executable, which reads the state from a
+// pipe, so that nothing they change, the working directory and descriptors included,
+// affects the shell.
+type subshellState struct {
+	List             string // Commands the subshell runs
+	Variables        map[string]variableState
+	Options          map[string]bool
+	IgnoredTraps     []string // Conditions whose signals are ignored, the only traps kept
+	ScriptName       string
+	PositionalParams []string
+	LastExitStatus   int
+	Interactive      bool
+	Pid              int // $$ stays the process ID of the shell
+	LastSubstitution int
+	StartTime        time.Time
+	SecondsStart     time.Time
+	InputLine        int
+	LineNumber       int
+	SourceFile       string
+	SourceDepth      int
+	CurrentCommand   string
+	History          []string
+	HashTable        map[string]hashedCommand
+	Descriptors      []int // Descriptors kept by exec, inherited under the same numbers
+}
+
+// variableState is a variable as it is sent to a subshell.
+type variableState struct {
+	Value      string
+	Elements   map[string]string
+	Attributes attribute
+	Set        bool
+	Dynamic    bool // The subshell computes the value itself, as for RANDOM
+}
+
+// hashedCommand is an entry of the hash table as it is sent to a subshell.
+type hashedCommand struct {
+	Path string
+	Hits int
+}
+
+// startSubshell starts a subshell process that runs list with the given standard streams.
+// The caller waits for it with waitSubshell.
+func (s *Shell) startSubshell(list string, inputStream *os.File, outputStream *os.File, errorStream *os.File) (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	stateReader, stateWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer stateWriter.Close()
+
+	// The descriptors exec opened keep their numbers, the state follows them
+	files := s.childFiles(&types.Command{})
+	files = append(files, stateReader)
+	cmd := exec.Command(executable, "--subshell", strconv.Itoa(2+len(files)))
+	cmd.Args[0] = os.Args[0]
+	cmd.Env = s.environment()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = inputStream, outputStream, errorStream
+	cmd.ExtraFiles = files
+	err = cmd.Start()
+	stateReader.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gob.NewEncoder(stateWriter).Encode(s.subshellState(list)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting subshell: %v\n", err)
+	}
+	return cmd, nil
+}
+
+// waitSubshell waits for a subshell process to end and returns its exit status.
+func waitSubshell(cmd *exec.Cmd) int {
+	return exitStatus(&types.Command{Name: "subshell", ErrorStream: os.Stderr}, cmd.Wait())
+}
+
+// subshellState collects the state a subshell that runs list starts with.
+func (s *Shell) subshellState(list string) *subshellState {
+	state := &subshellState{
+		List:             list,
+		Variables:        make(map[string]variableState),
+		Options:          make(map[string]bool),
+		ScriptName:       s.scriptName,
+		PositionalParams: s.PositionalParameters(),
+		LastExitStatus:   s.lastExitStatus,
+		Interactive:      s.interactive,
+		Pid:              s.pid,
+		StartTime:        s.startTime,
+		SecondsStart:     s.secondsStart,
+		InputLine:        s.inputLine,
+		LineNumber:       s.lineNumber,
+		SourceFile:       s.sourceFile,
+		SourceDepth:      s.sourceDepth,
+		CurrentCommand:   s.currentCommand,
+		History:          s.CommandsHistory,
+		HashTable:        make(map[string]hashedCommand),
+		Descriptors:      slices.Sorted(maps.Keys(s.descriptors)),
+	}
+	s.optionsLock.Lock()
+	maps.Copy(state.Options, s.options)
+	s.optionsLock.Unlock()
+	s.trapsLock.Lock()
+	for condition, action := range s.traps {
+		if action == "" {
+			state.IgnoredTraps = append(state.IgnoredTraps, condition)
+		}
+	}
+	s.trapsLock.Unlock()
+
+	s.variablesLock.Lock()
+	state.LastSubstitution = s.lastSubstitution
+	for name, v := range s.variables {
+		state.Variables[name] = variableState{Value: v.value, Elements: maps.Clone(v.elements),
+			Attributes: v.attributes, Set: v.set, Dynamic: v.get != nil}
+	}
+	s.variablesLock.Unlock()
+
+	s.pathLock.Lock()
+	for name, entry := range s.hashTable {
+		state.HashTable[name] = hashedCommand{Path: entry.path, Hits: entry.hits}
+	}
+	s.pathLock.Unlock()
+	return state
+}
+
+// runSubshell runs a subshell process with the state read from descriptor fd, and
+// returns the status it exits with: that of its commands, or the one exit gives.
+func (s *Shell) runSubshell(fd int) int {
+	file := os.NewFile(uintptr(fd), "subshell state")
+	var state subshellState
+	err := gob.NewDecoder(file).Decode(&state)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: reading subshell state: %v\n", os.Args[0], err)
+		return 2
+	}
+
+	dynamic := s.dynamicVariables()
+	s.variables = make(map[string]*variable, len(state.Variables))
+	for name, v := range state.Variables {
+		if d := dynamic[name]; v.Dynamic && d != nil {
+			d.attributes = v.Attributes
+			s.variables[name] = d
+			continue
+		}
+		s.variables[name] = &variable{value: v.Value, elements: v.Elements, attributes: v.Attributes, set: v.Set}
+	}
+	s.options = state.Options
+	s.scriptName, s.positionalParams = state.ScriptName, state.PositionalParams
+	s.lastExitStatus, s.interactive, s.pid = state.LastExitStatus, state.Interactive, state.Pid
+	s.lastSubstitution = state.LastSubstitution
+	s.startTime, s.secondsStart = state.StartTime, state.SecondsStart
+	s.inputLine, s.lineNumber = state.InputLine, state.LineNumber
+	s.sourceFile, s.sourceDepth = state.SourceFile, state.SourceDepth
+	s.currentCommand, s.CommandsHistory = state.CurrentCommand, state.History
+	s.searchPath, _ = s.Parameter("PATH")
+	for name, entry := range state.HashTable {
+		s.hashTable[name] = &hashEntry{path: entry.Path, hits: entry.Hits}
+	}
+	for _, condition := range state.IgnoredTraps {
+		s.traps[condition] = ""
+		s.updateSignalHandling(condition)
+	}
+	for _, fd := range state.Descriptors {
+		syscall.CloseOnExec(fd) // Only passed on to the commands that are given it
+		s.descriptors[fd] = os.NewFile(uintptr(fd), "/dev/fd/"+strconv.Itoa(fd))
+	}
+
+	s.processInput(state.List, os.Stdin, os.Stdout, os.Stderr)
+	return s.runExitTrap(s.lastExitStatus)
+}
@@ -0,0 +1,265 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// trapSignals are the signals a trap can be set for, in the order of their numbers.
+var trapSignals = []struct {
+	name   string
+	signal syscall.Signal
+}{
+	{"SIGHUP", syscall.SIGHUP}, {"SIGINT", syscall.SIGINT}, {"SIGQUIT", syscall.SIGQUIT},
+	{"SIGILL", syscall.SIGILL}, {"SIGTRAP", syscall.SIGTRAP}, {"SIGABRT", syscall.SIGABRT},
+	{"SIGBUS", syscall.SIGBUS}, {"SIGFPE", syscall.SIGFPE}, {"SIGKILL", syscall.SIGKILL},
+	{"SIGUSR1", syscall.SIGUSR1}, {"SIGSEGV", syscall.SIGSEGV}, {"SIGUSR2", syscall.SIGUSR2},
+	{"SIGPIPE", syscall.SIGPIPE}, {"SIGALRM", syscall.SIGALRM}, {"SIGTERM", syscall.SIGTERM},
+	{"SIGCHLD", syscall.SIGCHLD}, {"SIGCONT", syscall.SIGCONT}, {"SIGSTOP", syscall.SIGSTOP},
+	{"SIGTSTP", syscall.SIGTSTP}, {"SIGTTIN", syscall.SIGTTIN}, {"SIGTTOU", syscall.SIGTTOU},
+	{"SIGURG", syscall.SIGURG}, {"SIGXCPU", syscall.SIGXCPU}, {"SIGXFSZ", syscall.SIGXFSZ},
+	{"SIGVTALRM", syscall.SIGVTALRM}, {"SIGPROF", syscall.SIGPROF}, {"SIGWINCH", syscall.SIGWINCH},
+	{"SIGIO", syscall.SIGIO}, {"SIGSYS", syscall.SIGSYS},
+}
+
+// fatalSignals end a non-interactive shell by default. They are caught while an EXIT
+// trap is set, so that it still runs.
+var fatalSignals = []syscall.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM}
+
+// handleTrap handles the "trap" command:
+//
+//	trap [-lp] [[action] condition...]
+//
+// The action runs when one of the conditions occurs: a signal, or EXIT, ERR, DEBUG and
+// RETURN. An empty action ignores the signals, and "-" restores their default behaviour.
+func (s *Shell) handleTrap(command *types.Command) int {
+	args := command.Args
+	printTraps := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		option := args[0]
+		args = args[1:]
+		if option == "--" {
+			break
+		}
+		switch option {
+		case "-l":
+			printSignalNames(command)
+			return 0
+		case "-p":
+			printTraps = true
+		default:
+			fmt.Fprintf(command.ErrorStream, "trap: %s: invalid option\n", option)
+			fmt.Fprintln(command.ErrorStream, "trap: usage: trap [-lp] [[action] condition ...]")
+			return 2
+		}
+	}
+
+	if printTraps || len(args) == 0 {
+		return s.printTraps(command, args)
+	}
+
+	// The action can be left out to reset traps, which POSIX requires when the first
+	// condition is a signal number
+	action, reset := "", false
+	if _, err := strconv.ParseUint(args[0], 10, 32); len(args) == 1 || err == nil {
+		reset = true
+	} else {
+		action, reset, args = args[0], args[0] == "-", args[1:]
+	}
+
+	status := 0
+	for _, arg := range args {
+		condition, ok := parseCondition(arg)
+		if !ok {
+			fmt.Fprintf(command.ErrorStream, "trap: %s: invalid signal specification\n", arg)
+			status = 1
+			continue
+		}
+		s.trapsLock.Lock()
+		if reset {
+			delete(s.traps, condition)
+		} else {
+			s.traps[condition] = action
+		}
+		s.trapsLock.Unlock()
+		s.updateSignalHandling(condition)
+	}
+	return status
+}
+
+// trap returns the action of the trap for condition, and whether one is set.
+func (s *Shell) trap(condition string) (string, bool) {
+	s.trapsLock.Lock()
+	defer s.trapsLock.Unlock()
+	action, ok := s.traps[condition]
+	return action, ok
+}
+
+// parseCondition converts a condition given to trap, like INT, SIGINT, 2 or EXIT, to the
+// name the trap is stored under.
+func parseCondition(arg string) (string, bool) {
+	name := strings.ToUpper(arg)
+	switch name {
+	case "0", "EXIT":
+		return "EXIT", true
+	case "ERR", "DEBUG", "RETURN":
+		return name, true
+	}
+
+	if number, err := strconv.Atoi(name); err == nil {
+		for _, trapSignal := range trapSignals {
+			if int(trapSignal.signal) == number {
+				return trapSignal.name, true
+			}
+		}
+		return "", false
+	}
+	name = "SIG" + strings.TrimPrefix(name, "SIG")
+	for _, trapSignal := range trapSignals {
+		if trapSignal.name == name {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// conditionNames returns all trap conditions in the order traps are printed.
+func conditionNames() []string {
+	names := []string{"EXIT"}
+	for _, trapSignal := range trapSignals {
+		names = append(names, trapSignal.name)
+	}
+	return append(names, "DEBUG", "ERR", "RETURN")
+}
+
+// printTraps prints the traps for the given conditions, or all traps that are set, as
+// commands that set them again.
+func (s *Shell) printTraps(command *types.Command, args []string) int {
+	names := conditionNames()
+	if len(args) > 0 {
+		names = nil
+		for _, arg := range args {
+			condition, ok := parseCondition(arg)
+			if !ok {
+				fmt.Fprintf(command.ErrorStream, "trap: %s: invalid signal specification\n", arg)
+				return 1
+			}
+			names = append(names, condition)
+		}
+	}
+
+	for _, name := range names {
+		if action, ok := s.trap(name); ok {
+			quoted := "'" + strings.ReplaceAll(action, "'", `'\''`) + "'"
+			fmt.Fprintf(command.OutputStream, "trap -- %s %s\n", quoted, name)
+		}
+	}
+	return 0
+}
+
+func printSignalNames(command *types.Command) {
+	for i, trapSignal := range trapSignals {
+		separator := "\t"
+		if (i+1)%5 == 0 || i == len(trapSignals)-1 {
+			separator = "\n"
+		}
+		fmt.Fprintf(command.OutputStream, "%2d) %s%s", int(trapSignal.signal), trapSignal.name, separator)
+	}
+}
+
+// updateSignalHandling makes the shell catch, ignore or no longer handle signals after the
+// trap for condition changed. Ignored signals stay ignored in the commands the shell runs.
+func (s *Shell) updateSignalHandling(condition string) {
+	for _, trapSignal := range trapSignals {
+		sig := trapSignal.signal
+		if trapSignal.name != condition && (condition != "EXIT" || !slices.Contains(fatalSignals, sig)) {
+			continue
+		}
+
+		action, trapped := s.trap(trapSignal.name)
+		_, exitTrapped := s.trap("EXIT")
+		switch {
+		case trapped && action == "":
+			signal.Ignore(sig)
+		case trapped || (exitTrapped && !s.interactive && slices.Contains(fatalSignals, sig)):
+			signal.Reset(sig) // undo an earlier Ignore
+			signal.Notify(s.signals, sig)
+		default:
+			signal.Reset(sig)
+		}
+	}
+}
+
+// runPendingTraps runs the traps for the signals received since the last call. Returns
+// true if the shell should exit, because a trap ran exit or a fatal signal was received.
+func (s *Shell) runPendingTraps() bool {
+	if s.inTrap {
+		return false // signals arriving during a trap are handled after it
+	}
+	for {
+		select {
+		case received := <-s.signals:
+			sig := received.(syscall.Signal)
+			name := ""
+			for _, trapSignal := range trapSignals {
+				if trapSignal.signal == sig {
+					name = trapSignal.name
+				}
+			}
+			if _, trapped := s.trap(name); !trapped {
+				// Caught for the EXIT trap only, the shell ends as if killed by it
+				s.lastExitStatus = 128 + int(sig)
+				return true
+			}
+			if s.runTrap(name) {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// runTrap runs the trap for condition, if one is set. The trap does not change $?, but
+// returns true if it ran exit.
+func (s *Shell) runTrap(condition string) bool {
+	action, _ := s.trap(condition)
+	if action == "" || s.inTrap {
+		return false
+	}
+
+	status := s.lastExitStatus
+	s.inTrap = true
+	exitShell := s.processInput(action, os.Stdin, os.Stdout, os.Stderr)
+	s.inTrap = false
+	if !exitShell {
+		s.lastExitStatus = status
+	}
+	return exitShell
+}
+
+// runExitTrap runs the EXIT trap when the shell is about to exit with status, and
+// returns the status the shell exits with, which exit in the trap may change.
+func (s *Shell) runExitTrap(status int) int {
+	s.trapsLock.Lock()
+	action, ok := s.traps["EXIT"]
+	delete(s.traps, "EXIT") // runs at most once
+	s.trapsLock.Unlock()
+	if !ok {
+		return status
+	}
+
+	s.lastExitStatus = status
+	s.inTrap = true
+	if s.processInput(action, os.Stdin, os.Stdout, os.Stderr) {
+		return s.lastExitStatus
+	}
+	return status
+}
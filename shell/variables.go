@@ -0,0 +1,497 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/arithmetic"
+	"github.com/codecrafters-io/shell-starter-go/parser"
+	"github.com/codecrafters-io/shell-starter-go/types"
+)
+
+// attribute is a property of a variable, set with the options of declare.
+type attribute uint16
+
+const (
+	exported     attribute = 1 << iota // Passed to the environment of child processes
+	readOnly                           // Cannot be assigned to or unset
+	integer                            // Assigned values are evaluated as arithmetic expressions
+	lowercase                          // Assigned values are converted to lowercase
+	uppercase                          // Assigned values are converted to uppercase
+	indexedArray                       // Elements are indexed by numbers
+	assocArray                         // Elements are indexed by strings
+	nameref                            // The value is the name of the variable used instead
+)
+
+// attributeLetters pairs attributes with their options of declare, in the order declare -p
+// prints them.
+var attributeLetters = []struct {
+	letter    byte
+	attribute attribute
+}{
+	{'a', indexedArray}, {'A', assocArray}, {'i', integer}, {'n', nameref},
+	{'r', readOnly}, {'x', exported}, {'l', lowercase}, {'u', uppercase},
+}
+
+// maxNamerefDepth limits how many namerefs are followed, which stops reference loops.
+const maxNamerefDepth = 8
+
+// variable is a shell variable. A scalar keeps its value in value and an array its
+// elements in elements.
+type variable struct {
+	value      string
+	elements   map[string]string // Elements of an array by index or key
+	attributes attribute
+	set        bool // false if the variable was declared without giving it a value
+
+	// Dynamic variables like RANDOM compute their value with get each time they are read,
+	// and react to assignments with assigned. Neither may take the variables lock.
+	get      func() string
+	assigned func(value string)
+}
+
+// scalar returns the value of a scalar variable.
+func (v *variable) scalar() string {
+	if v.get != nil {
+		return v.get()
+	}
+	return v.value
+}
+
+func (v *variable) isArray() bool {
+	return v.attributes&(indexedArray|assocArray) != 0
+}
+
+// keys returns the indices of an array in order, numerically for indexed arrays.
+func (v *variable) keys() []string {
+	keys := make([]string, 0, len(v.elements))
+	for key := range v.elements {
+		keys = append(keys, key)
+	}
+	if v.attributes&indexedArray != 0 {
+		slices.SortFunc(keys, func(a, b string) int {
+			x, _ := strconv.Atoi(a)
+			y, _ := strconv.Atoi(b)
+			return x - y
+		})
+	} else {
+		slices.Sort(keys)
+	}
+	return keys
+}
+
+// loadEnvironment turns the environment the shell was started with into exported
+// variables. Like in bash, IFS is not taken from the environment but starts out as the
+// default separators.
+func loadEnvironment() map[string]*variable {
+	variables := map[string]*variable{"IFS": {value: " \t\n", set: true}}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if parser.IsValidName(name) && name != "IFS" {
+			variables[name] = &variable{value: value, attributes: exported, set: true}
+		}
+	}
+	return variables
+}
+
+// resolve follows namerefs from name to the variable they refer to. The lock must be held.
+func (s *Shell) resolve(name string) string {
+	for range maxNamerefDepth {
+		v := s.variables[name]
+		if v == nil || v.attributes&nameref == 0 || !v.set || !parser.IsValidName(v.value) {
+			break
+		}
+		name = v.value
+	}
+	return name
+}
+
+// lookupVariable returns the value of a variable. An array name alone refers to its
+// element 0.
+func (s *Shell) lookupVariable(name string) (string, bool) {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	v := s.variables[s.resolve(name)]
+	switch {
+	case v == nil || !v.set:
+		return "", false
+	case v.isArray():
+		value, ok := v.elements["0"]
+		return value, ok
+	default:
+		return v.scalar(), true
+	}
+}
+
+// SetVariable assigns a value to a variable, or to element 0 if it is an array. The name
+// may have a subscript, as in name[index], to assign to another element.
+func (s *Shell) SetVariable(name string, value string) error {
+	if arrayName, index, ok := parser.SplitSubscript(name); ok {
+		return s.applyAssignment(types.Assignment{Name: arrayName, Index: index, Value: value})
+	}
+	s.variablesLock.Lock()
+	name = s.resolve(name)
+	s.variablesLock.Unlock()
+	return s.assign(name, value)
+}
+
+// assign assigns a value to the named variable without following namerefs.
+func (s *Shell) assign(name string, value string) error {
+	value, err := s.convertValue(name, value)
+	if err != nil {
+		return err
+	}
+
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+	v := s.variable(name)
+	if v.isArray() {
+		v.elements["0"] = value
+	} else {
+		v.value = value
+	}
+	v.set = true
+	if v.assigned != nil {
+		v.assigned(value)
+	}
+	return nil
+}
+
+// setElement assigns a value to the element of the named variable with the given key,
+// turning a scalar into an indexed array.
+func (s *Shell) setElement(name string, key string, value string) error {
+	value, err := s.convertValue(name, value)
+	if err != nil {
+		return err
+	}
+
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+	v := s.variable(name)
+	if !v.isArray() {
+		v.attributes |= indexedArray
+		v.elements = make(map[string]string)
+		if v.set {
+			v.elements["0"] = v.value
+		}
+	}
+	v.elements[key] = value
+	v.set = true
+	return nil
+}
+
+// convertValue converts a value about to be assigned to the named variable according to
+// its attributes. Returns an error if the variable is readonly.
+func (s *Shell) convertValue(name string, value string) (string, error) {
+	s.variablesLock.Lock()
+	var attributes attribute
+	if v := s.variables[name]; v != nil {
+		attributes = v.attributes
+	}
+	s.variablesLock.Unlock()
+	if attributes&readOnly != 0 {
+		return "", fmt.Errorf("%s: readonly variable", name)
+	}
+
+	// Arithmetic may read other variables, so it is evaluated without holding the lock
+	switch {
+	case attributes&integer != 0:
+		n, err := arithmetic.Evaluate(value, s)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(n, 10), nil
+	case attributes&lowercase != 0:
+		return strings.ToLower(value), nil
+	case attributes&uppercase != 0:
+		return strings.ToUpper(value), nil
+	}
+	return value, nil
+}
+
+// hasAttribute reports whether the named variable, without following namerefs, has an
+// attribute.
+func (s *Shell) hasAttribute(name string, attribute attribute) bool {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	v := s.variables[name]
+	return v != nil && v.attributes&attribute != 0
+}
+
+// elementKey returns the key of the element index refers to. For associative arrays that
+// is the index itself, for indexed arrays the index is an arithmetic expression, which
+// counts from the end if it is negative.
+func (s *Shell) elementKey(name string, index string) (string, error) {
+	if s.hasAttribute(name, assocArray) {
+		if index == "" {
+			return "", fmt.Errorf("%s: bad array subscript", name)
+		}
+		return index, nil
+	}
+	n, err := arithmetic.Evaluate(index, s)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 {
+		s.variablesLock.Lock()
+		n += int64(s.nextIndex(name))
+		s.variablesLock.Unlock()
+		if n < 0 {
+			return "", fmt.Errorf("%s[%s]: bad array subscript", name, index)
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// nextIndex returns the index after the highest one of an indexed array, where "+="
+// appends. The lock must be held.
+func (s *Shell) nextIndex(name string) int {
+	v := s.variables[name]
+	switch {
+	case v == nil || !v.set:
+		return 0
+	case !v.isArray():
+		return 1
+	}
+	next := 0
+	for key := range v.elements {
+		if n, err := strconv.Atoi(key); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return next
+}
+
+// checkAssignable returns an error if the named variable cannot be assigned to.
+func (s *Shell) checkAssignable(name string) error {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	name = s.resolve(name)
+	if v := s.variables[name]; v != nil && v.attributes&readOnly != 0 {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	return nil
+}
+
+// variable returns the named variable, creating it if it does not exist. The lock must be held.
+func (s *Shell) variable(name string) *variable {
+	v := s.variables[name]
+	if v == nil {
+		v = &variable{}
+		s.variables[name] = v
+	}
+	if v.isArray() && v.elements == nil {
+		v.elements = make(map[string]string)
+	}
+	return v
+}
+
+// setArray assigns values to an indexed array, or unsets it if values is nil.
+func (s *Shell) setArray(name string, values []string) error {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	name = s.resolve(name)
+	if v := s.variables[name]; v != nil && v.attributes&readOnly != 0 {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if values == nil {
+		delete(s.variables, name)
+		return nil
+	}
+	v := s.variable(name)
+	v.attributes = v.attributes&^assocArray | indexedArray
+	v.elements = make(map[string]string, len(values))
+	for i, value := range values {
+		v.elements[strconv.Itoa(i)] = value
+	}
+	v.set = true
+	return nil
+}
+
+// Element returns an element of an array variable. A scalar is an array with element 0.
+func (s *Shell) Element(name string, index string) (string, bool) {
+	s.variablesLock.Lock()
+	name = s.resolve(name)
+	s.variablesLock.Unlock()
+	key, err := s.elementKey(name, index)
+	if err != nil {
+		return "", false
+	}
+
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+	v := s.variables[name]
+	if v == nil || !v.set {
+		return "", false
+	}
+	if !v.isArray() {
+		return v.scalar(), key == "0"
+	}
+	value, ok := v.elements[key]
+	return value, ok
+}
+
+// Elements returns the indices and values of an array variable in order. A scalar with a
+// value is an array with the single index 0.
+func (s *Shell) Elements(name string) ([]string, []string) {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	v := s.variables[s.resolve(name)]
+	switch {
+	case v == nil || !v.set:
+		return nil, nil
+	case !v.isArray():
+		return []string{"0"}, []string{v.scalar()}
+	}
+	keys := v.keys()
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = v.elements[key]
+	}
+	return keys, values
+}
+
+// unsetVariable removes a variable. If unsetNameref is set, a nameref itself is removed
+// instead of the variable it refers to.
+func (s *Shell) unsetVariable(name string, unsetNameref bool) error {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	if !unsetNameref {
+		name = s.resolve(name)
+	}
+	if v := s.variables[name]; v != nil && v.attributes&readOnly != 0 {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+	delete(s.variables, name)
+	return nil
+}
+
+// unsetElement removes one element of an array, or the whole array for the index @ or *.
+func (s *Shell) unsetElement(name string, index string) error {
+	s.variablesLock.Lock()
+	name = s.resolve(name)
+	s.variablesLock.Unlock()
+	if index == "@" || index == "*" {
+		return s.unsetVariable(name, false)
+	}
+	if err := s.checkAssignable(name); err != nil {
+		return err
+	}
+	key, err := s.elementKey(name, index)
+	if err != nil {
+		return err
+	}
+
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+	switch v := s.variables[name]; {
+	case v == nil:
+	case v.isArray():
+		delete(v.elements, key)
+	case key == "0":
+		delete(s.variables, name)
+	}
+	return nil
+}
+
+// environment returns the exported variables in the "name=value" form child processes
+// receive them in.
+func (s *Shell) environment() []string {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	var environment []string
+	for name, v := range s.variables {
+		if v.attributes&exported != 0 && v.set && !v.isArray() {
+			environment = append(environment, name+"="+v.scalar())
+		}
+	}
+	slices.Sort(environment)
+	return environment
+}
+
+// variableNames returns the names of all variables in order. The lock must be held.
+func (s *Shell) variableNames() []string {
+	names := make([]string, 0, len(s.variables))
+	for name := range s.variables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// declaration describes a variable as a declare command that recreates it.
+func declaration(name string, v *variable) string {
+	letters := ""
+	for _, attribute := range attributeLetters {
+		if v.attributes&attribute.attribute != 0 {
+			letters += string(attribute.letter)
+		}
+	}
+	if letters == "" {
+		letters = "-"
+	}
+
+	result := "declare -" + letters + " " + name
+	switch {
+	case !v.set:
+	case v.isArray():
+		result += "=" + arrayValue(v)
+	default:
+		result += "=" + doubleQuote(v.scalar())
+	}
+	return result
+}
+
+// arrayValue writes the elements of an array in the form of a compound assignment.
+func arrayValue(v *variable) string {
+	elements := []string{}
+	for _, key := range v.keys() {
+		elements = append(elements, "["+key+"]="+doubleQuote(v.elements[key]))
+	}
+	result := "(" + strings.Join(elements, " ")
+	if v.attributes&assocArray != 0 && len(elements) > 0 {
+		result += " " // like bash
+	}
+	return result + ")"
+}
+
+// doubleQuote puts s in double quotes, escaping the characters that are special in them.
+func doubleQuote(s string) string {
+	var result strings.Builder
+	result.WriteByte('"')
+	for _, r := range s {
+		if strings.ContainsRune("\\\"$`", r) {
+			result.WriteByte('\\')
+		}
+		result.WriteRune(r)
+	}
+	result.WriteByte('"')
+	return result.String()
+}
+
+// printVariables prints every variable with a value as an assignment, like "set" does.
+func (s *Shell) printVariables(output *os.File) {
+	s.variablesLock.Lock()
+	defer s.variablesLock.Unlock()
+
+	for _, name := range s.variableNames() {
+		v := s.variables[name]
+		switch {
+		case !v.set:
+		case v.isArray():
+			fmt.Fprintf(output, "%s=%s\n", name, arrayValue(v))
+		default:
+			fmt.Fprintf(output, "%s=%s\n", name, parser.Quote(v.scalar()))
+		}
+	}
+}
@@ -1,13 +1,58 @@
 package types
 
-import "os"
+import (
+	"os"
+	"strings"
+)
 
 // Command represents a parsed shell command.
 type Command struct {
-	Name         string
-	Args         []string
+	Name        string
+	Args        []string
+	Assignments []Assignment // Variable assignments before the name
+	// Declarations are the arguments of declaration builtins like declare and export
+	// that are assignments, by their index in Args
+	Declarations map[int]Assignment
 	InputStream  *os.File
 	OutputStream *os.File
 	ErrorStream  *os.File
+	ExtraFiles   []*os.File // Inherited by external commands, entry i becomes fd 3+i
+	// Descriptors are the files the command's redirections leave each redirected
+	// descriptor with, nil for the ones they close
+	Descriptors map[int]*os.File
 	// Potentially add InputStream for '<' redirects later
 }
+
+// Assignment is an expanded variable assignment: name=value, name+=value,
+// name[index]=value or name=(element...).
+type Assignment struct {
+	Name     string   // Variable name
+	Index    string   // Index of the element assigned by name[index]=value, empty for none
+	Value    string   // Value, if it is not Compound
+	Append   bool     // "+=" appends to the value or array instead of replacing it
+	Compound bool     // The value is a list of array elements, as in name=(element...)
+	Elements []string // Elements of a compound value
+	Indices  []string // Indices of the elements written [index]=value, empty for the others
+}
+
+// String returns the assignment in the form it is written in, without quoting.
+func (a Assignment) String() string {
+	name := a.Name
+	if a.Index != "" {
+		name += "[" + a.Index + "]"
+	}
+	if a.Append {
+		name += "+"
+	}
+	if !a.Compound {
+		return name + "=" + a.Value
+	}
+	elements := make([]string, len(a.Elements))
+	for i, element := range a.Elements {
+		if a.Indices[i] != "" {
+			element = "[" + a.Indices[i] + "]=" + element
+		}
+		elements[i] = element
+	}
+	return name + "=(" + strings.Join(elements, " ") + ")"
+}